@@ -0,0 +1,687 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hclparse"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+	"github.com/terraform-providers/terraform-provider-rabbitmq/rabbitmq"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// Functions available to the configuration. Terraform has many more, but
+// these are the ones commonly used to build the attributes of RabbitMQ
+// objects.
+var configFunctions = map[string]function.Function{
+	"abs":        stdlib.AbsoluteFunc,
+	"coalesce":   stdlib.CoalesceFunc,
+	"concat":     stdlib.ConcatFunc,
+	"format":     stdlib.FormatFunc,
+	"formatlist": stdlib.FormatListFunc,
+	"jsondecode": stdlib.JSONDecodeFunc,
+	"jsonencode": stdlib.JSONEncodeFunc,
+	"length":     stdlib.LengthFunc,
+	"lower":      stdlib.LowerFunc,
+	"max":        stdlib.MaxFunc,
+	"min":        stdlib.MinFunc,
+	"substr":     stdlib.SubstrFunc,
+	"upper":      stdlib.UpperFunc,
+}
+
+var configFileSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "variable", LabelNames: []string{"name"}},
+		{Type: "locals"},
+		{Type: "resource", LabelNames: []string{"type", "name"}},
+	},
+}
+
+var variableSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "default"}},
+}
+
+// Meta-arguments that create several instances of a resource, which can't be
+// expanded without Terraform.
+var resourceRepetitionArguments = []string{"count", "for_each"}
+
+// configResource is a resource block of the configuration. Its values are
+// shaped like the values read from the ResourceData of the provider.
+type configResource struct {
+	resourceType string
+	name         string
+	body         hcl.Body
+	schema       map[string]*schema.Schema
+	values       map[string]interface{}
+	attrs        map[string]cty.Value
+}
+
+func (r *configResource) address() string {
+	return r.resourceType + "." + r.name
+}
+
+// loadConfig returns the objects managed by the resources of the Terraform
+// configuration in dir. Resources that can't be evaluated without Terraform,
+// e.g. because they depend on data sources or on count, are skipped and
+// reported as warnings.
+func loadConfig(dir string, vars map[string]string) (*objects, []string, error) {
+	files, err := configFiles(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	provider := rabbitmq.Provider().(*schema.Provider)
+	parser := hclparse.NewParser()
+
+	variables := map[string]cty.Value{}
+	locals := map[string]hcl.Expression{}
+	var resources []*configResource
+	var warnings []string
+
+	for _, path := range files {
+		var file *hcl.File
+		var diags hcl.Diagnostics
+		if strings.HasSuffix(path, ".json") {
+			file, diags = parser.ParseJSONFile(path)
+		} else {
+			file, diags = parser.ParseHCLFile(path)
+		}
+		if diags.HasErrors() {
+			return nil, nil, diags
+		}
+
+		content, _, diags := file.Body.PartialContent(configFileSchema)
+		if diags.HasErrors() {
+			return nil, nil, diags
+		}
+
+		for _, block := range content.Blocks {
+			switch block.Type {
+			case "variable":
+				value, err := variableValue(block, vars)
+				if err != nil {
+					return nil, nil, err
+				}
+				variables[block.Labels[0]] = value
+
+			case "locals":
+				attrs, diags := block.Body.JustAttributes()
+				if diags.HasErrors() {
+					return nil, nil, diags
+				}
+				for name, attr := range attrs {
+					locals[name] = attr.Expr
+				}
+
+			case "resource":
+				r, ok := provider.ResourcesMap[block.Labels[0]]
+				if !ok {
+					continue
+				}
+
+				resource := &configResource{
+					resourceType: block.Labels[0],
+					name:         block.Labels[1],
+					body:         block.Body,
+					schema:       r.Schema,
+				}
+
+				repeated, err := resourceRepeated(block.Body)
+				if err != nil {
+					return nil, nil, err
+				}
+				if repeated {
+					warnings = append(warnings, fmt.Sprintf("%s: count and for_each aren't supported, the resource is skipped", resource.address()))
+					continue
+				}
+
+				resources = append(resources, resource)
+			}
+		}
+	}
+
+	cwd, _ := os.Getwd()
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var":  cty.ObjectVal(variables),
+			"data": cty.DynamicVal,
+			"path": cty.ObjectVal(map[string]cty.Value{
+				"module": cty.StringVal(dir),
+				"root":   cty.StringVal(dir),
+				"cwd":    cty.StringVal(cwd),
+			}),
+		},
+		Functions: configFunctions,
+	}
+
+	unresolved := evaluateConfig(ctx, locals, resources)
+
+	o := &objects{}
+	for _, resource := range resources {
+		if err, ok := unresolved[resource]; ok {
+			warnings = append(warnings, fmt.Sprintf("%s: %s, the resource is skipped", resource.address(), err))
+			continue
+		}
+		if err := o.addResource(resource); err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", resource.address(), err)
+		}
+	}
+
+	return o, warnings, nil
+}
+
+func configFiles(dir string) ([]string, error) {
+	var files []string
+	for _, pattern := range []string{"*.tf", "*.tf.json"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("No Terraform configuration files in %s", dir)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// variableValue returns the value of a variable: the value given on the
+// command line or in TF_VAR_ environment variables, or its default.
+// Variables without a value are unknown.
+func variableValue(block *hcl.Block, vars map[string]string) (cty.Value, error) {
+	name := block.Labels[0]
+	if v, ok := vars[name]; ok {
+		return cty.StringVal(v), nil
+	}
+	if v, ok := os.LookupEnv("TF_VAR_" + name); ok {
+		return cty.StringVal(v), nil
+	}
+
+	content, _, diags := block.Body.PartialContent(variableSchema)
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+
+	attr, ok := content.Attributes["default"]
+	if !ok {
+		return cty.DynamicVal, nil
+	}
+
+	value, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+
+	return value, nil
+}
+
+func resourceRepeated(body hcl.Body) (bool, error) {
+	bodySchema := &hcl.BodySchema{}
+	for _, name := range resourceRepetitionArguments {
+		bodySchema.Attributes = append(bodySchema.Attributes, hcl.AttributeSchema{Name: name})
+	}
+
+	content, _, diags := body.PartialContent(bodySchema)
+	if diags.HasErrors() {
+		return false, diags
+	}
+
+	return len(content.Attributes) > 0, nil
+}
+
+// evaluateConfig evaluates the locals and resources, until the values they
+// reference are all known. It returns the resources that can't be evaluated.
+func evaluateConfig(ctx *hcl.EvalContext, locals map[string]hcl.Expression, resources []*configResource) map[*configResource]error {
+	localValues := map[string]cty.Value{}
+	unresolved := map[*configResource]error{}
+	for _, resource := range resources {
+		unresolved[resource] = nil
+	}
+
+	for progress := true; progress; {
+		progress = false
+
+		ctx.Variables["local"] = cty.ObjectVal(localValues)
+		for name, expr := range locals {
+			if _, ok := localValues[name]; ok {
+				continue
+			}
+			value, diags := expr.Value(ctx)
+			if !diags.HasErrors() && value.IsWhollyKnown() {
+				localValues[name] = value
+				progress = true
+			}
+		}
+
+		ctx.Variables["local"] = cty.ObjectVal(localValues)
+		setResourceVariables(ctx, resources, unresolved)
+
+		for _, resource := range resources {
+			if _, ok := unresolved[resource]; !ok {
+				continue
+			}
+
+			values, attrs, err := decodeBody(resource.body, resource.schema, ctx)
+			if err != nil {
+				unresolved[resource] = err
+				continue
+			}
+
+			resource.values = values
+			resource.attrs = attrs
+			delete(unresolved, resource)
+			progress = true
+		}
+	}
+
+	return unresolved
+}
+
+// setResourceVariables makes the attributes of the evaluated resources
+// available to the expressions referencing them.
+func setResourceVariables(ctx *hcl.EvalContext, resources []*configResource, unresolved map[*configResource]error) {
+	byType := map[string]map[string]cty.Value{}
+	for _, resource := range resources {
+		if _, ok := unresolved[resource]; ok {
+			continue
+		}
+		if byType[resource.resourceType] == nil {
+			byType[resource.resourceType] = map[string]cty.Value{}
+		}
+		byType[resource.resourceType][resource.name] = cty.ObjectVal(resource.attrs)
+	}
+
+	for resourceType, byName := range byType {
+		ctx.Variables[resourceType] = cty.ObjectVal(byName)
+	}
+}
+
+// decodeBody decodes a body the way the provider would read it from its
+// ResourceData: attributes that aren't set get their default, or the zero
+// value of their type. It also returns the evaluated attributes, which other
+// resources may reference.
+func decodeBody(body hcl.Body, sm map[string]*schema.Schema, ctx *hcl.EvalContext) (map[string]interface{}, map[string]cty.Value, error) {
+	bodySchema := &hcl.BodySchema{}
+	for name, s := range sm {
+		if s.Computed && !s.Optional && !s.Required {
+			continue
+		}
+		if _, ok := s.Elem.(*schema.Resource); ok {
+			bodySchema.Blocks = append(bodySchema.Blocks, hcl.BlockHeaderSchema{Type: name})
+		} else {
+			bodySchema.Attributes = append(bodySchema.Attributes, hcl.AttributeSchema{Name: name})
+		}
+	}
+
+	content, _, diags := body.PartialContent(bodySchema)
+	if diags.HasErrors() {
+		return nil, nil, diags
+	}
+
+	values := map[string]interface{}{}
+	attrs := map[string]cty.Value{}
+
+	for name, attr := range content.Attributes {
+		value, diags := attr.Expr.Value(ctx)
+		if diags.HasErrors() {
+			return nil, nil, diags
+		}
+		if !value.IsWhollyKnown() {
+			return nil, nil, fmt.Errorf("%s depends on values only known by Terraform", name)
+		}
+
+		v, err := schemaValue(value, sm[name])
+		if err != nil {
+			return nil, nil, fmt.Errorf("Invalid value for %s: %s", name, err)
+		}
+		values[name] = v
+		attrs[name] = value
+	}
+
+	for _, block := range content.Blocks {
+		elem := sm[block.Type].Elem.(*schema.Resource)
+		blockValues, _, err := decodeBody(block.Body, elem.Schema, ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		list, _ := values[block.Type].([]interface{})
+		values[block.Type] = append(list, blockValues)
+	}
+
+	for name, s := range sm {
+		if _, ok := values[name]; !ok {
+			values[name] = schemaZeroValue(s)
+		}
+	}
+
+	return values, attrs, nil
+}
+
+// schemaValue converts a value to the type of a schema, the way Terraform
+// does.
+func schemaValue(value cty.Value, s *schema.Schema) (interface{}, error) {
+	if value.IsNull() {
+		return schemaZeroValue(s), nil
+	}
+
+	switch s.Type {
+	case schema.TypeString:
+		v, err := convert.Convert(value, cty.String)
+		if err != nil {
+			return nil, err
+		}
+		return v.AsString(), nil
+
+	case schema.TypeInt:
+		v, err := convert.Convert(value, cty.Number)
+		if err != nil {
+			return nil, err
+		}
+		i, accuracy := v.AsBigFloat().Int64()
+		if accuracy != 0 {
+			return nil, fmt.Errorf("%s is not an integer", v.AsBigFloat().String())
+		}
+		return int(i), nil
+
+	case schema.TypeFloat:
+		v, err := convert.Convert(value, cty.Number)
+		if err != nil {
+			return nil, err
+		}
+		f, _ := v.AsBigFloat().Float64()
+		return f, nil
+
+	case schema.TypeBool:
+		v, err := convert.Convert(value, cty.Bool)
+		if err != nil {
+			return nil, err
+		}
+		return v.True(), nil
+
+	case schema.TypeMap:
+		v, err := convert.Convert(value, cty.Map(cty.String))
+		if err != nil {
+			return nil, err
+		}
+		m := map[string]interface{}{}
+		for key, elem := range v.AsValueMap() {
+			m[key] = elem.AsString()
+		}
+		return m, nil
+
+	case schema.TypeList, schema.TypeSet:
+		v, err := convert.Convert(value, cty.List(cty.String))
+		if err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for _, elem := range v.AsValueSlice() {
+			list = append(list, elem.AsString())
+		}
+		return list, nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", s.Type)
+}
+
+func schemaZeroValue(s *schema.Schema) interface{} {
+	if s.Default != nil {
+		return s.Default
+	}
+
+	switch s.Type {
+	case schema.TypeString:
+		return ""
+	case schema.TypeInt:
+		return 0
+	case schema.TypeFloat:
+		return 0.0
+	case schema.TypeBool:
+		return false
+	case schema.TypeMap:
+		return map[string]interface{}{}
+	default:
+		return []interface{}{}
+	}
+}
+
+func stringValue(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+func boolValue(m map[string]interface{}, key string) bool {
+	b, _ := m[key].(bool)
+	return b
+}
+
+func blockValues(m map[string]interface{}, key string) []map[string]interface{} {
+	list, _ := m[key].([]interface{})
+	blocks := make([]map[string]interface{}, 0, len(list))
+	for _, v := range list {
+		if block, ok := v.(map[string]interface{}); ok {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+func firstBlock(m map[string]interface{}, key string) map[string]interface{} {
+	if blocks := blockValues(m, key); len(blocks) > 0 {
+		return blocks[0]
+	}
+	return map[string]interface{}{}
+}
+
+// configArguments returns the arguments of a block with arguments and
+// arguments_json attributes.
+func configArguments(m map[string]interface{}) (map[string]interface{}, error) {
+	if v := stringValue(m, "arguments_json"); v != "" {
+		var arguments map[string]interface{}
+		if err := json.Unmarshal([]byte(v), &arguments); err != nil {
+			return nil, err
+		}
+		return arguments, nil
+	}
+
+	arguments, _ := m["arguments"].(map[string]interface{})
+	return arguments, nil
+}
+
+// addResource adds the objects managed by a resource.
+func (o *objects) addResource(r *configResource) error {
+	v := r.values
+	name := stringValue(v, "name")
+	vhost := stringValue(v, "vhost")
+
+	switch r.resourceType {
+	case "rabbitmq_vhost":
+		o.Vhosts = append(o.Vhosts, rabbithole.VhostInfo{Name: name})
+
+	case "rabbitmq_user", "rabbitmq_service_account":
+		o.Users = append(o.Users, rabbithole.UserInfo{
+			Name:         name,
+			PasswordHash: stringValue(v, "password_hash"),
+			Tags:         configTags(v),
+		})
+
+		if r.resourceType == "rabbitmq_service_account" {
+			for _, p := range blockValues(v, "permissions") {
+				o.Permissions = append(o.Permissions, permissionInfo(name, stringValue(p, "vhost"), p))
+			}
+			for _, p := range blockValues(v, "topic_permissions") {
+				o.TopicPermissions = append(o.TopicPermissions, topicPermissionInfo(name, stringValue(p, "vhost"), p))
+			}
+		}
+
+	case "rabbitmq_permissions":
+		o.Permissions = append(o.Permissions, permissionInfo(stringValue(v, "user"), vhost, firstBlock(v, "permissions")))
+
+	case "rabbitmq_vhost_permissions":
+		for _, p := range blockValues(v, "permissions") {
+			o.Permissions = append(o.Permissions, permissionInfo(stringValue(p, "user"), vhost, p))
+		}
+
+	case "rabbitmq_topic_permissions":
+		for _, p := range blockValues(v, "permissions") {
+			o.TopicPermissions = append(o.TopicPermissions, topicPermissionInfo(stringValue(v, "user"), vhost, p))
+		}
+
+	case "rabbitmq_exchange":
+		settings := firstBlock(v, "settings")
+		arguments, _ := settings["arguments"].(map[string]interface{})
+		o.Exchanges = append(o.Exchanges, rabbithole.ExchangeInfo{
+			Name:       name,
+			Vhost:      vhost,
+			Type:       stringValue(settings, "type"),
+			Durable:    boolValue(settings, "durable"),
+			AutoDelete: boolValue(settings, "auto_delete"),
+			Arguments:  arguments,
+		})
+
+	case "rabbitmq_queue":
+		settings := firstBlock(v, "settings")
+		arguments, err := configArguments(settings)
+		if err != nil {
+			return err
+		}
+		o.Queues = append(o.Queues, rabbithole.QueueInfo{
+			Name:       name,
+			Vhost:      vhost,
+			Durable:    boolValue(settings, "durable"),
+			AutoDelete: boolValue(settings, "auto_delete"),
+			Arguments:  arguments,
+		})
+
+	case "rabbitmq_binding":
+		arguments, err := configArguments(v)
+		if err != nil {
+			return err
+		}
+		o.Bindings = append(o.Bindings, rabbithole.BindingInfo{
+			Source:          stringValue(v, "source"),
+			Vhost:           vhost,
+			Destination:     stringValue(v, "destination"),
+			DestinationType: stringValue(v, "destination_type"),
+			RoutingKey:      stringValue(v, "routing_key"),
+			Arguments:       arguments,
+		})
+
+	case "rabbitmq_exchange_bindings":
+		for _, b := range blockValues(v, "binding") {
+			arguments, _ := b["arguments"].(map[string]interface{})
+			o.Bindings = append(o.Bindings, rabbithole.BindingInfo{
+				Source:          stringValue(v, "source"),
+				Vhost:           vhost,
+				Destination:     stringValue(b, "destination"),
+				DestinationType: stringValue(b, "destination_type"),
+				RoutingKey:      stringValue(b, "routing_key"),
+				Arguments:       arguments,
+			})
+		}
+
+	case "rabbitmq_queue_bindings":
+		for _, b := range blockValues(v, "binding") {
+			arguments, _ := b["arguments"].(map[string]interface{})
+			o.Bindings = append(o.Bindings, rabbithole.BindingInfo{
+				Source:          stringValue(b, "source"),
+				Vhost:           vhost,
+				Destination:     stringValue(v, "destination"),
+				DestinationType: "queue",
+				RoutingKey:      stringValue(b, "routing_key"),
+				Arguments:       arguments,
+			})
+		}
+
+	case "rabbitmq_policy":
+		policy := firstBlock(v, "policy")
+		definition, err := rabbitmq.PolicyDefinition(policy)
+		if err != nil {
+			return err
+		}
+		priority, _ := policy["priority"].(int)
+		o.Policies = append(o.Policies, rabbithole.Policy{
+			Name:       name,
+			Vhost:      vhost,
+			Pattern:    stringValue(policy, "pattern"),
+			Priority:   priority,
+			ApplyTo:    stringValue(policy, "apply_to"),
+			Definition: definition,
+		})
+
+	case "rabbitmq_shovel":
+		o.Shovels = append(o.Shovels, rabbithole.ShovelInfo{
+			Name:       name,
+			Vhost:      vhost,
+			Component:  "shovel",
+			Definition: rabbitmq.ShovelDefinition(firstBlock(v, "info")),
+		})
+
+	case "rabbitmq_user_password_rotation":
+		rotation := userRotation{name: name, tags: configTags(v)}
+		for _, p := range blockValues(v, "permissions") {
+			rotation.permissions = append(rotation.permissions, permissionInfo("", stringValue(p, "vhost"), p))
+		}
+		o.rotations = append(o.rotations, rotation)
+
+	case "rabbitmq_definitions":
+		definitions, err := parseDefinitions([]byte(stringValue(v, "definitions")), "document", vhost)
+		if err != nil {
+			return err
+		}
+		o.add(definitions)
+
+	case "rabbitmq_federation_upstream":
+		o.FederationUpstreams = append(o.FederationUpstreams, rabbithole.FederationUpstream{
+			Name:       name,
+			Vhost:      vhost,
+			Component:  "federation-upstream",
+			Definition: rabbitmq.FederationDefinition(firstBlock(v, "definition")),
+		})
+	}
+
+	return nil
+}
+
+// configTags returns the tags of a user as RabbitMQ reports them.
+func configTags(v map[string]interface{}) string {
+	tags := []string{}
+	for _, tag := range v["tags"].([]interface{}) {
+		tags = append(tags, tag.(string))
+	}
+	sort.Strings(tags)
+	return strings.Join(tags, ",")
+}
+
+func permissionInfo(user, vhost string, p map[string]interface{}) rabbithole.PermissionInfo {
+	return rabbithole.PermissionInfo{
+		User:      user,
+		Vhost:     vhost,
+		Configure: stringValue(p, "configure"),
+		Write:     stringValue(p, "write"),
+		Read:      stringValue(p, "read"),
+	}
+}
+
+func topicPermissionInfo(user, vhost string, p map[string]interface{}) rabbithole.TopicPermissionInfo {
+	return rabbithole.TopicPermissionInfo{
+		User:     user,
+		Vhost:    vhost,
+		Exchange: stringValue(p, "exchange"),
+		Write:    stringValue(p, "write"),
+		Read:     stringValue(p, "read"),
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rabbitmq-terraform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := `
+variable "vhost" {}
+
+locals {
+  ttl = 60000
+}
+
+resource "rabbitmq_vhost" "test" {
+  name = "${var.vhost}"
+}
+
+resource "rabbitmq_queue" "test" {
+  name  = "orders"
+  vhost = rabbitmq_vhost.test.name
+
+  settings {
+    durable        = true
+    arguments_json = jsonencode({ "x-max-length" = 10 })
+  }
+}
+
+resource "rabbitmq_policy" "test" {
+  name  = "ttl"
+  vhost = rabbitmq_vhost.test.name
+
+  policy {
+    pattern  = ".*"
+    priority = 0
+    apply_to = "queues"
+
+    definition = {
+      message-ttl = local.ttl
+    }
+  }
+}
+
+resource "rabbitmq_queue_bindings" "test" {
+  destination = rabbitmq_queue.test.name
+  vhost       = rabbitmq_vhost.test.name
+
+  binding {
+    source      = "events"
+    routing_key = "orders.#"
+  }
+}
+
+resource "rabbitmq_queue" "repeated" {
+  count = 2
+  name  = "queue-${count.index}"
+
+  settings {}
+}
+
+resource "rabbitmq_user" "hashed" {
+  name          = "app"
+  password_hash = data.rabbitmq_password_hash.app.password_hash
+}
+
+resource "rabbitmq_user_password_rotation" "worker" {
+  name = "worker"
+  tags = ["monitoring"]
+
+  permissions {
+    vhost     = rabbitmq_vhost.test.name
+    configure = ""
+    write     = ""
+    read      = ".*"
+  }
+}
+
+resource "rabbitmq_definitions" "test" {
+  vhost = rabbitmq_vhost.test.name
+
+  definitions = jsonencode({
+    queues = [{ name = "events", durable = true, auto_delete = false, arguments = {} }]
+  })
+}
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.tf"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o, warnings, err := loadConfig(dir, map[string]string{"vhost": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(warnings) != 2 {
+		t.Errorf("Expected warnings for the repeated queue and the hashed user, got %#v", warnings)
+	}
+
+	if !reflect.DeepEqual(o.Vhosts, []rabbithole.VhostInfo{{Name: "test"}}) {
+		t.Errorf("Unexpected vhosts %#v", o.Vhosts)
+	}
+
+	queue := o.Queues[0]
+	if queue.Vhost != "test" || !queue.Durable || queue.AutoDelete || queue.Arguments["x-max-length"] != float64(10) {
+		t.Errorf("Unexpected queue %#v", queue)
+	}
+
+	// The definition is converted the way the provider converts it.
+	policy := o.Policies[0]
+	if policy.Definition["message-ttl"] != int64(60000) {
+		t.Errorf("Unexpected policy definition %#v", policy.Definition)
+	}
+
+	if len(o.Queues) != 2 || o.Queues[1].Name != "events" || o.Queues[1].Vhost != "test" {
+		t.Errorf("Expected the queue of the definitions, got %#v", o.Queues)
+	}
+
+	if len(o.rotations) != 1 || o.rotations[0].name != "worker" || o.rotations[0].tags != "monitoring" || o.rotations[0].permissions[0].Vhost != "test" {
+		t.Errorf("Unexpected rotations %#v", o.rotations)
+	}
+
+	binding := o.Bindings[0]
+	if binding.Source != "events" || binding.Destination != "orders" || binding.DestinationType != "queue" || binding.RoutingKey != "orders.#" {
+		t.Errorf("Unexpected binding %#v", binding)
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/structure"
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+	"github.com/terraform-providers/terraform-provider-rabbitmq/rabbitmq"
+)
+
+const (
+	diffFormatText = "text"
+	diffFormatJson = "json"
+)
+
+// Kinds of objects, in the order they are reported.
+var diffKinds = []string{
+	"vhost",
+	"user",
+	"permissions",
+	"topic_permissions",
+	"exchange",
+	"queue",
+	"binding",
+	"policy",
+	"shovel",
+	"federation_upstream",
+}
+
+// diffObject is an object identified by its kind and ID, with the fields
+// that are compared.
+type diffObject struct {
+	kind   string
+	id     string
+	fields map[string]interface{}
+}
+
+type diffReport struct {
+	Added    []diffEntry `json:"added"`
+	Changed  []diffEntry `json:"changed"`
+	Removed  []diffEntry `json:"removed"`
+	Warnings []string    `json:"warnings,omitempty"`
+}
+
+type diffEntry struct {
+	Kind    string        `json:"kind"`
+	ID      string        `json:"id"`
+	Changes []fieldChange `json:"changes,omitempty"`
+}
+
+type fieldChange struct {
+	Field   string      `json:"field"`
+	Current interface{} `json:"current"`
+	Desired interface{} `json:"desired"`
+}
+
+func (r *diffReport) empty() bool {
+	return len(r.Added) == 0 && len(r.Changed) == 0 && len(r.Removed) == 0
+}
+
+// diffObjects compares the current objects with the desired ones. Objects
+// of kinds that are missing from the current objects, e.g. users in the
+// export of a vhost, aren't compared. Only the fields of the desired objects
+// are compared, and their values are compared as JSON, the way the provider
+// compares JSON attributes. The password rotations of the desired objects are
+// replaced by their users.
+func diffObjects(current, desired *objects) (*diffReport, error) {
+	desired.expandRotations(current)
+
+	currentObjects, err := current.diffObjects()
+	if err != nil {
+		return nil, err
+	}
+	desiredObjects, err := desired.diffObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &diffReport{
+		Added:   []diffEntry{},
+		Changed: []diffEntry{},
+		Removed: []diffEntry{},
+	}
+
+	for _, kind := range diffKinds {
+		if !current.hasKind(kind) {
+			continue
+		}
+
+		cur := currentObjects[kind]
+		des := desiredObjects[kind]
+
+		for _, id := range sortedIds(des) {
+			d := des[id]
+			c, ok := cur[id]
+			if !ok {
+				report.Added = append(report.Added, diffEntry{Kind: kind, ID: id})
+				continue
+			}
+
+			changes, err := diffFields(kind, c.fields, d.fields)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to compare %s %s: %s", kind, id, err)
+			}
+			if len(changes) > 0 {
+				report.Changed = append(report.Changed, diffEntry{Kind: kind, ID: id, Changes: changes})
+			}
+		}
+
+		for _, id := range sortedIds(cur) {
+			if _, ok := des[id]; !ok {
+				report.Removed = append(report.Removed, diffEntry{Kind: kind, ID: id})
+			}
+		}
+	}
+
+	return report, nil
+}
+
+func sortedIds(objects map[string]diffObject) []string {
+	ids := make([]string, 0, len(objects))
+	for id := range objects {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func diffFields(kind string, current, desired map[string]interface{}) ([]fieldChange, error) {
+	fields := make([]string, 0, len(desired))
+	for field := range desired {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var changes []fieldChange
+	for _, field := range fields {
+		// SuppressJsonDiff compares JSON objects.
+		c, err := json.Marshal(map[string]interface{}{field: current[field]})
+		if err != nil {
+			return nil, err
+		}
+		d, err := json.Marshal(map[string]interface{}{field: desired[field]})
+		if err != nil {
+			return nil, err
+		}
+
+		if !structure.SuppressJsonDiff(field, string(c), string(d), nil) {
+			changes = append(changes, fieldChange{
+				Field:   field,
+				Current: redactedDiffValue(kind, field, current[field]),
+				Desired: redactedDiffValue(kind, field, desired[field]),
+			})
+		}
+	}
+
+	return changes, nil
+}
+
+const sensitiveDiffValue = "(sensitive)"
+
+// redactedDiffValue hides the credentials of a reported value. Password
+// hashes aren't reported, while only the passwords of the URIs of shovels and
+// federation upstreams are hidden.
+func redactedDiffValue(kind, field string, v interface{}) interface{} {
+	if field == "password_hash" {
+		if s, _ := v.(string); s != "" {
+			return sensitiveDiffValue
+		}
+		return v
+	}
+
+	if kind != "shovel" && kind != "federation_upstream" {
+		return v
+	}
+
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return sensitiveDiffValue
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(bytes, &decoded); err != nil {
+		return sensitiveDiffValue
+	}
+
+	return redactedUris(decoded)
+}
+
+func redactedUris(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, val := range value {
+			value[key] = redactedUris(val)
+		}
+	case []interface{}:
+		for i, val := range value {
+			value[i] = redactedUris(val)
+		}
+	case string:
+		return rabbitmq.RedactURI(value)
+	}
+	return v
+}
+
+// normalisedJson encodes a value as JSON, with the types it has once
+// decoded, so that e.g. integers and floats of the same value are equal.
+func normalisedJson(v interface{}) (string, error) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(bytes, &decoded); err != nil {
+		return "", err
+	}
+
+	bytes, err = json.Marshal(decoded)
+	return string(bytes), err
+}
+
+func nonNilArguments(arguments map[string]interface{}) map[string]interface{} {
+	if arguments == nil {
+		return map[string]interface{}{}
+	}
+	return arguments
+}
+
+// diffObjects returns the objects by kind and ID.
+func (o *objects) diffObjects() (map[string]map[string]diffObject, error) {
+	result := map[string]map[string]diffObject{}
+	add := func(kind, id string, fields map[string]interface{}) {
+		if result[kind] == nil {
+			result[kind] = map[string]diffObject{}
+		}
+		result[kind][id] = diffObject{kind: kind, id: id, fields: fields}
+	}
+
+	for _, v := range o.Vhosts {
+		add("vhost", v.Name, map[string]interface{}{})
+	}
+
+	for _, u := range o.Users {
+		tags := []string{}
+		for _, tag := range strings.Split(u.Tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		sort.Strings(tags)
+
+		fields := map[string]interface{}{"tags": tags}
+		// Users configured with a password don't declare their hash.
+		if u.PasswordHash != "" {
+			fields["password_hash"] = u.PasswordHash
+		}
+		add("user", u.Name, fields)
+	}
+
+	for _, p := range o.Permissions {
+		add("permissions", rabbitmq.ResourceId(p.User, p.Vhost), map[string]interface{}{
+			"configure": p.Configure,
+			"write":     p.Write,
+			"read":      p.Read,
+		})
+	}
+
+	for _, p := range o.TopicPermissions {
+		add("topic_permissions", fmt.Sprintf("%s exchange %s", rabbitmq.ResourceId(p.User, p.Vhost), p.Exchange), map[string]interface{}{
+			"write": p.Write,
+			"read":  p.Read,
+		})
+	}
+
+	for _, e := range o.Exchanges {
+		add("exchange", rabbitmq.ResourceId(e.Name, e.Vhost), map[string]interface{}{
+			"type":        e.Type,
+			"durable":     e.Durable,
+			"auto_delete": e.AutoDelete,
+			"arguments":   nonNilArguments(e.Arguments),
+		})
+	}
+
+	for _, q := range o.Queues {
+		add("queue", rabbitmq.ResourceId(q.Name, q.Vhost), map[string]interface{}{
+			"durable":     q.Durable,
+			"auto_delete": q.AutoDelete,
+			"arguments":   nonNilArguments(q.Arguments),
+		})
+	}
+
+	for _, b := range o.Bindings {
+		id, err := bindingDiffId(b)
+		if err != nil {
+			return nil, err
+		}
+		add("binding", id, map[string]interface{}{})
+	}
+
+	for _, p := range o.Policies {
+		add("policy", rabbitmq.ResourceId(p.Name, p.Vhost), map[string]interface{}{
+			"pattern":    p.Pattern,
+			"priority":   p.Priority,
+			"apply-to":   p.ApplyTo,
+			"definition": p.Definition,
+		})
+	}
+
+	for _, s := range o.Shovels {
+		add("shovel", rabbitmq.ResourceId(s.Name, s.Vhost), map[string]interface{}{
+			"value": s.Definition,
+		})
+	}
+
+	for _, u := range o.FederationUpstreams {
+		add("federation_upstream", rabbitmq.ResourceId(u.Name, u.Vhost), map[string]interface{}{
+			"value": u.Definition,
+		})
+	}
+
+	return result, nil
+}
+
+// bindingDiffId identifies a binding by all of its properties, since
+// changing any of them replaces the binding.
+func bindingDiffId(b rabbithole.BindingInfo) (string, error) {
+	id := fmt.Sprintf("%s to %s %s", rabbitmq.ResourceId(b.Source, b.Vhost), b.DestinationType, b.Destination)
+	if b.RoutingKey != "" {
+		id += fmt.Sprintf(" with routing key %q", b.RoutingKey)
+	}
+
+	if len(b.Arguments) > 0 {
+		arguments, err := normalisedJson(b.Arguments)
+		if err != nil {
+			return "", err
+		}
+		id += " and arguments " + arguments
+	}
+
+	return id, nil
+}
+
+func (r *diffReport) writeText(w io.Writer) error {
+	for _, e := range r.Added {
+		fmt.Fprintf(w, "+ %s %s\n", e.Kind, e.ID)
+	}
+
+	for _, e := range r.Changed {
+		fmt.Fprintf(w, "~ %s %s\n", e.Kind, e.ID)
+		for _, c := range e.Changes {
+			current, err := json.Marshal(c.Current)
+			if err != nil {
+				return err
+			}
+			desired, err := json.Marshal(c.Desired)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "    %s: %s => %s\n", c.Field, current, desired)
+		}
+	}
+
+	for _, e := range r.Removed {
+		fmt.Fprintf(w, "- %s %s\n", e.Kind, e.ID)
+	}
+
+	if r.empty() {
+		fmt.Fprintln(w, "No changes.")
+	} else {
+		fmt.Fprintf(w, "\n%d to add, %d to change, %d to remove.\n", len(r.Added), len(r.Changed), len(r.Removed))
+	}
+
+	if len(r.Warnings) > 0 {
+		fmt.Fprintln(w, "\nWarnings:")
+		for _, warning := range r.Warnings {
+			fmt.Fprintf(w, "  %s\n", warning)
+		}
+	}
+
+	return nil
+}
+
+func (r *diffReport) writeJson(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
@@ -0,0 +1,694 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+	"github.com/terraform-providers/terraform-provider-rabbitmq/rabbitmq"
+)
+
+const (
+	importModeBlocks   = "blocks"
+	importModeCommands = "commands"
+)
+
+// hclBlock is a block of the generated configuration. Attribute values are
+// already encoded as HCL.
+type hclBlock struct {
+	header string
+	attrs  []hclAttr
+	blocks []*hclBlock
+}
+
+type hclAttr struct {
+	name  string
+	value string
+}
+
+func newBlock(header string) *hclBlock {
+	return &hclBlock{header: header}
+}
+
+func (b *hclBlock) attr(name, value string) *hclBlock {
+	b.attrs = append(b.attrs, hclAttr{name: name, value: value})
+	return b
+}
+
+func (b *hclBlock) block(header string) *hclBlock {
+	child := newBlock(header)
+	b.blocks = append(b.blocks, child)
+	return child
+}
+
+// write writes the block the way terraform fmt would, with the equal signs
+// of the attributes aligned.
+func (b *hclBlock) write(w io.Writer, indent string) {
+	fmt.Fprintf(w, "%s%s {\n", indent, b.header)
+
+	width := 0
+	for _, a := range b.attrs {
+		if len(a.name) > width {
+			width = len(a.name)
+		}
+	}
+	for _, a := range b.attrs {
+		value := strings.Replace(a.value, "\n", "\n"+indent+"  ", -1)
+		fmt.Fprintf(w, "%s  %-*s = %s\n", indent, width, a.name, value)
+	}
+
+	for i, child := range b.blocks {
+		if i > 0 || len(b.attrs) > 0 {
+			fmt.Fprintln(w)
+		}
+		child.write(w, indent+"  ")
+	}
+
+	fmt.Fprintf(w, "%s}\n", indent)
+}
+
+// hclString encodes a string as an HCL literal, escaping template sequences
+// so that they aren't interpolated.
+func hclString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		case (r == '$' || r == '%') && strings.HasPrefix(s[i+1:], "{"):
+			b.WriteRune(r)
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func hclBool(v bool) string {
+	return strconv.FormatBool(v)
+}
+
+func hclInt(v int) string {
+	return strconv.Itoa(v)
+}
+
+func hclStringList(values []string) string {
+	encoded := make([]string, len(values))
+	for i, v := range values {
+		encoded[i] = hclString(v)
+	}
+	return "[" + strings.Join(encoded, ", ") + "]"
+}
+
+// hclStringMap encodes a map of strings, with sorted and aligned keys.
+func hclStringMap(m map[string]string) string {
+	if len(m) == 0 {
+		return "{}"
+	}
+
+	keys := make([]string, 0, len(m))
+	width := 0
+	for k := range m {
+		keys = append(keys, k)
+		if l := len(hclString(k)); l > width {
+			width = l
+		}
+	}
+	sort.Strings(keys)
+
+	lines := []string{"{"}
+	for _, k := range keys {
+		lines = append(lines, fmt.Sprintf("  %-*s = %s", width, hclString(k), hclString(m[k])))
+	}
+	lines = append(lines, "}")
+
+	return strings.Join(lines, "\n")
+}
+
+// hclJson encodes a value as a JSON string, so that its types are kept.
+func hclJson(v interface{}) (string, error) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return hclString(string(bytes)), nil
+}
+
+// stringArguments returns the arguments as strings, unless one of them isn't
+// a string.
+func stringArguments(arguments map[string]interface{}) (map[string]string, bool) {
+	strs := make(map[string]string, len(arguments))
+	for k, v := range arguments {
+		s, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		strs[k] = s
+	}
+	return strs, true
+}
+
+// policyDefinitionMap returns the values of the definition attribute of a
+// policy, as long as they are converted back to the same values by the
+// provider: strings and integers.
+func policyDefinitionMap(definition rabbithole.PolicyDefinition) (map[string]string, bool) {
+	m := make(map[string]string, len(definition))
+	for k, v := range definition {
+		switch x := v.(type) {
+		case string:
+			if _, err := strconv.ParseInt(x, 10, 64); err == nil {
+				return nil, false
+			}
+			m[k] = x
+		case float64:
+			if x != float64(int64(x)) {
+				return nil, false
+			}
+			m[k] = strconv.FormatInt(int64(x), 10)
+		case json.Number:
+			if _, err := x.Int64(); err != nil {
+				return nil, false
+			}
+			m[k] = x.String()
+		default:
+			return nil, false
+		}
+	}
+	return m, true
+}
+
+var resourceNameInvalid = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// generator generates resource blocks, and the imports of the resources.
+type generator struct {
+	blocks   []*hclBlock
+	comments map[*hclBlock][]string
+	imports  []generatedImport
+	names    map[string]bool
+}
+
+type generatedImport struct {
+	address string
+	id      string
+}
+
+func newGenerator() *generator {
+	return &generator{
+		comments: map[*hclBlock][]string{},
+		names:    map[string]bool{},
+	}
+}
+
+// resourceName returns a unique resource name derived from the parts.
+// Objects of the default vhost aren't prefixed with it.
+func (g *generator) resourceName(resourceType string, vhost string, parts ...string) string {
+	if vhost != "" && vhost != "/" {
+		parts = append([]string{vhost}, parts...)
+	}
+
+	name := strings.Trim(resourceNameInvalid.ReplaceAllString(strings.ToLower(strings.Join(parts, "_")), "_"), "_")
+	if name == "" {
+		name = "default"
+	}
+	if name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+
+	unique := name
+	for i := 2; g.names[resourceType+"."+unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	g.names[resourceType+"."+unique] = true
+
+	return unique
+}
+
+// resource adds a resource block. Resources without an import ID aren't
+// imported.
+func (g *generator) resource(resourceType, name, id string) *hclBlock {
+	block := newBlock(fmt.Sprintf("resource %q %q", resourceType, name))
+	g.blocks = append(g.blocks, block)
+	if id != "" {
+		g.imports = append(g.imports, generatedImport{address: resourceType + "." + name, id: id})
+	}
+	return block
+}
+
+func (g *generator) comment(block *hclBlock, format string, args ...interface{}) {
+	g.comments[block] = append(g.comments[block], fmt.Sprintf(format, args...))
+}
+
+// generate generates the resources of the objects.
+func (g *generator) generate(o *objects) error {
+	g.vhosts(o.Vhosts)
+	g.users(o.Users)
+	g.permissions(o.Permissions)
+	g.topicPermissions(o.TopicPermissions)
+	g.exchanges(o.Exchanges)
+	if err := g.queues(o.Queues); err != nil {
+		return err
+	}
+	if err := g.bindings(o.Bindings); err != nil {
+		return err
+	}
+	if err := g.policies(o.Policies); err != nil {
+		return err
+	}
+	g.shovels(o.Shovels)
+	g.federationUpstreams(o.FederationUpstreams)
+	return nil
+}
+
+func (g *generator) vhosts(vhosts []rabbithole.VhostInfo) {
+	sort.Slice(vhosts, func(i, j int) bool { return vhosts[i].Name < vhosts[j].Name })
+
+	for _, v := range vhosts {
+		name := g.resourceName("rabbitmq_vhost", "", v.Name)
+		g.resource("rabbitmq_vhost", name, v.Name).
+			attr("name", hclString(v.Name))
+	}
+}
+
+func (g *generator) users(users []rabbithole.UserInfo) {
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+
+	for _, u := range users {
+		name := g.resourceName("rabbitmq_user", "", u.Name)
+		block := g.resource("rabbitmq_user", name, u.Name).
+			attr("name", hclString(u.Name))
+
+		if u.PasswordHash != "" {
+			block.attr("password_hash", hclString(u.PasswordHash))
+			if algorithm := u.HashingAlgorithm.String(); algorithm != "" {
+				block.attr("hashing_algorithm", hclString(algorithm))
+			}
+		} else {
+			block.attr("passwordless", hclBool(true))
+		}
+
+		tags := []string{}
+		for _, tag := range strings.Split(u.Tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		if len(tags) > 0 {
+			sort.Strings(tags)
+			block.attr("tags", hclStringList(tags))
+		}
+	}
+}
+
+func (g *generator) permissions(permissions []rabbithole.PermissionInfo) {
+	sort.Slice(permissions, func(i, j int) bool {
+		if permissions[i].Vhost != permissions[j].Vhost {
+			return permissions[i].Vhost < permissions[j].Vhost
+		}
+		return permissions[i].User < permissions[j].User
+	})
+
+	for _, p := range permissions {
+		name := g.resourceName("rabbitmq_permissions", p.Vhost, p.User)
+		block := g.resource("rabbitmq_permissions", name, rabbitmq.ResourceId(p.User, p.Vhost)).
+			attr("user", hclString(p.User)).
+			attr("vhost", hclString(p.Vhost))
+		block.block("permissions").
+			attr("configure", hclString(p.Configure)).
+			attr("write", hclString(p.Write)).
+			attr("read", hclString(p.Read))
+	}
+}
+
+// topicPermissions generates a resource for the topic permissions of each
+// user and vhost, as rabbitmq_topic_permissions holds the permissions for
+// every exchange.
+func (g *generator) topicPermissions(permissions []rabbithole.TopicPermissionInfo) {
+	sort.Slice(permissions, func(i, j int) bool {
+		if permissions[i].Vhost != permissions[j].Vhost {
+			return permissions[i].Vhost < permissions[j].Vhost
+		}
+		if permissions[i].User != permissions[j].User {
+			return permissions[i].User < permissions[j].User
+		}
+		return permissions[i].Exchange < permissions[j].Exchange
+	})
+
+	var block *hclBlock
+	var previous rabbithole.TopicPermissionInfo
+	for i, p := range permissions {
+		if i == 0 || p.User != previous.User || p.Vhost != previous.Vhost {
+			name := g.resourceName("rabbitmq_topic_permissions", p.Vhost, p.User)
+			block = g.resource("rabbitmq_topic_permissions", name, rabbitmq.ResourceId(p.User, p.Vhost)).
+				attr("user", hclString(p.User)).
+				attr("vhost", hclString(p.Vhost))
+		}
+		block.block("permissions").
+			attr("exchange", hclString(p.Exchange)).
+			attr("write", hclString(p.Write)).
+			attr("read", hclString(p.Read))
+		previous = p
+	}
+}
+
+// exchanges generates the exchanges. The arguments of rabbitmq_exchange are
+// strings, so other values are converted.
+func (g *generator) exchanges(exchanges []rabbithole.ExchangeInfo) {
+	sort.Slice(exchanges, func(i, j int) bool {
+		if exchanges[i].Vhost != exchanges[j].Vhost {
+			return exchanges[i].Vhost < exchanges[j].Vhost
+		}
+		return exchanges[i].Name < exchanges[j].Name
+	})
+
+	for _, e := range exchanges {
+		name := g.resourceName("rabbitmq_exchange", e.Vhost, e.Name)
+		block := g.resource("rabbitmq_exchange", name, rabbitmq.ResourceId(e.Name, e.Vhost)).
+			attr("name", hclString(e.Name)).
+			attr("vhost", hclString(e.Vhost))
+
+		settings := block.block("settings").
+			attr("type", hclString(e.Type)).
+			attr("durable", hclBool(e.Durable)).
+			attr("auto_delete", hclBool(e.AutoDelete))
+
+		if len(e.Arguments) > 0 {
+			arguments := make(map[string]string, len(e.Arguments))
+			for k, v := range e.Arguments {
+				if s, ok := v.(string); ok {
+					arguments[k] = s
+				} else {
+					bytes, _ := json.Marshal(v)
+					arguments[k] = string(bytes)
+					g.comment(block, "argument %s was converted to a string", k)
+				}
+			}
+			settings.attr("arguments", hclStringMap(arguments))
+		}
+	}
+}
+
+func (g *generator) queues(queues []rabbithole.QueueInfo) error {
+	sort.Slice(queues, func(i, j int) bool {
+		if queues[i].Vhost != queues[j].Vhost {
+			return queues[i].Vhost < queues[j].Vhost
+		}
+		return queues[i].Name < queues[j].Name
+	})
+
+	for _, q := range queues {
+		name := g.resourceName("rabbitmq_queue", q.Vhost, q.Name)
+		block := g.resource("rabbitmq_queue", name, rabbitmq.ResourceId(q.Name, q.Vhost)).
+			attr("name", hclString(q.Name)).
+			attr("vhost", hclString(q.Vhost))
+
+		settings := block.block("settings").
+			attr("durable", hclBool(q.Durable)).
+			attr("auto_delete", hclBool(q.AutoDelete))
+
+		if err := argumentsAttr(settings, q.Arguments); err != nil {
+			return fmt.Errorf("Unable to encode arguments of queue %s in vhost %s: %s", q.Name, q.Vhost, err)
+		}
+	}
+
+	return nil
+}
+
+// argumentsAttr sets arguments when every argument is a string, and
+// arguments_json otherwise.
+func argumentsAttr(block *hclBlock, arguments map[string]interface{}) error {
+	if len(arguments) == 0 {
+		return nil
+	}
+
+	if strs, ok := stringArguments(arguments); ok {
+		block.attr("arguments", hclStringMap(strs))
+		return nil
+	}
+
+	encoded, err := hclJson(arguments)
+	if err != nil {
+		return err
+	}
+	block.attr("arguments_json", encoded)
+
+	return nil
+}
+
+func (g *generator) bindings(bindings []rabbithole.BindingInfo) error {
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindingSortKey(bindings[i]) < bindingSortKey(bindings[j])
+	})
+
+	for _, b := range bindings {
+		id := ""
+		propertiesKey, ok := bindingPropertiesKey(b)
+		if ok {
+			b.PropertiesKey = propertiesKey
+			id = rabbitmq.BindingId(b)
+		}
+
+		name := g.resourceName("rabbitmq_binding", b.Vhost, b.Source, b.Destination)
+		block := g.resource("rabbitmq_binding", name, id).
+			attr("source", hclString(b.Source)).
+			attr("vhost", hclString(b.Vhost)).
+			attr("destination", hclString(b.Destination)).
+			attr("destination_type", hclString(b.DestinationType))
+		if b.RoutingKey != "" {
+			block.attr("routing_key", hclString(b.RoutingKey))
+		}
+
+		if err := argumentsAttr(block, b.Arguments); err != nil {
+			return fmt.Errorf("Unable to encode arguments of binding %s to %s in vhost %s: %s", b.Source, b.Destination, b.Vhost, err)
+		}
+
+		if !ok {
+			g.comment(block, "not imported: definitions don't include the properties key of bindings with arguments, read it from the management API")
+		}
+	}
+
+	return nil
+}
+
+func bindingSortKey(b rabbithole.BindingInfo) string {
+	bytes, _ := json.Marshal([]string{b.Vhost, b.Source, b.DestinationType, b.Destination, b.RoutingKey, b.PropertiesKey})
+	return string(bytes)
+}
+
+// bindingPropertiesKey returns the properties key of a binding. Definitions
+// exports don't include it, but it can be derived from the routing key of
+// bindings without arguments, the way RabbitMQ does.
+func bindingPropertiesKey(b rabbithole.BindingInfo) (string, bool) {
+	if b.PropertiesKey != "" {
+		return b.PropertiesKey, true
+	}
+	if len(b.Arguments) > 0 {
+		return "", false
+	}
+	if b.RoutingKey == "" {
+		return "~", true
+	}
+	return strings.Replace(url.QueryEscape(b.RoutingKey), "~", "%7E", -1), true
+}
+
+func (g *generator) policies(policies []rabbithole.Policy) error {
+	sort.Slice(policies, func(i, j int) bool {
+		if policies[i].Vhost != policies[j].Vhost {
+			return policies[i].Vhost < policies[j].Vhost
+		}
+		return policies[i].Name < policies[j].Name
+	})
+
+	for _, p := range policies {
+		name := g.resourceName("rabbitmq_policy", p.Vhost, p.Name)
+		block := g.resource("rabbitmq_policy", name, rabbitmq.ResourceId(p.Name, p.Vhost)).
+			attr("name", hclString(p.Name)).
+			attr("vhost", hclString(p.Vhost))
+
+		policy := block.block("policy").
+			attr("pattern", hclString(p.Pattern)).
+			attr("priority", hclInt(p.Priority)).
+			attr("apply_to", hclString(p.ApplyTo))
+
+		if definition, ok := policyDefinitionMap(p.Definition); ok {
+			policy.attr("definition", hclStringMap(definition))
+			continue
+		}
+
+		encoded, err := hclJson(p.Definition)
+		if err != nil {
+			return fmt.Errorf("Unable to encode definition of policy %s in vhost %s: %s", p.Name, p.Vhost, err)
+		}
+		policy.attr("definition_json", encoded)
+	}
+
+	return nil
+}
+
+// shovels generates the shovels with the current names of the attributes of
+// the info block. Attributes left to their default aren't set.
+func (g *generator) shovels(shovels []rabbithole.ShovelInfo) {
+	sort.Slice(shovels, func(i, j int) bool {
+		if shovels[i].Vhost != shovels[j].Vhost {
+			return shovels[i].Vhost < shovels[j].Vhost
+		}
+		return shovels[i].Name < shovels[j].Name
+	})
+
+	for _, s := range shovels {
+		name := g.resourceName("rabbitmq_shovel", s.Vhost, s.Name)
+		block := g.resource("rabbitmq_shovel", name, rabbitmq.ResourceId(s.Name, s.Vhost)).
+			attr("name", hclString(s.Name)).
+			attr("vhost", hclString(s.Vhost))
+
+		d := s.Definition
+		info := block.block("info")
+
+		strs := []struct {
+			name  string
+			value string
+		}{
+			{"source_uri", d.SourceURI},
+			{"source_protocol", d.SourceProtocol},
+			{"source_queue", d.SourceQueue},
+			{"source_exchange", d.SourceExchange},
+			{"source_exchange_key", d.SourceExchangeKey},
+			{"source_address", d.SourceAddress},
+			{"source_delete_after", firstNonEmpty(d.SourceDeleteAfter, d.DeleteAfter)},
+			{"destination_uri", d.DestinationURI},
+			{"destination_protocol", d.DestinationProtocol},
+			{"destination_queue", d.DestinationQueue},
+			{"destination_exchange", d.DestinationExchange},
+			{"destination_exchange_key", d.DestinationExchangeKey},
+			{"destination_address", d.DestinationAddress},
+			{"destination_application_properties", d.DestinationApplicationProperties},
+			{"destination_properties", d.DestinationProperties},
+			{"destination_publish_properties", d.DestinationPublishProperties},
+			{"ack_mode", d.AckMode},
+		}
+		for _, a := range strs {
+			if a.value != "" {
+				info.attr(a.name, hclString(a.value))
+			}
+		}
+
+		if prefetchCount := d.SourcePrefetchCount; prefetchCount != 0 || d.PrefetchCount != 0 {
+			if prefetchCount == 0 {
+				prefetchCount = d.PrefetchCount
+			}
+			info.attr("source_prefetch_count", hclInt(prefetchCount))
+		}
+		if d.ReconnectDelay != 0 {
+			info.attr("reconnect_delay", hclInt(d.ReconnectDelay))
+		}
+		if d.DestinationAddForwardHeaders || d.AddForwardHeaders {
+			info.attr("destination_add_forward_headers", hclBool(true))
+		}
+		if d.DestinationAddTimestampHeader {
+			info.attr("destination_add_timestamp_header", hclBool(true))
+		}
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (g *generator) federationUpstreams(upstreams []rabbithole.FederationUpstream) {
+	sort.Slice(upstreams, func(i, j int) bool {
+		if upstreams[i].Vhost != upstreams[j].Vhost {
+			return upstreams[i].Vhost < upstreams[j].Vhost
+		}
+		return upstreams[i].Name < upstreams[j].Name
+	})
+
+	for _, u := range upstreams {
+		name := g.resourceName("rabbitmq_federation_upstream", u.Vhost, u.Name)
+		block := g.resource("rabbitmq_federation_upstream", name, rabbitmq.ResourceId(u.Name, u.Vhost)).
+			attr("name", hclString(u.Name)).
+			attr("vhost", hclString(u.Vhost))
+
+		d := u.Definition
+		definition := block.block("definition").
+			attr("uri", hclString(d.Uri)).
+			attr("prefetch_count", hclInt(d.PrefetchCount)).
+			attr("reconnect_delay", hclInt(d.ReconnectDelay)).
+			attr("ack_mode", hclString(firstNonEmpty(d.AckMode, "on-confirm"))).
+			attr("trust_user_id", hclBool(d.TrustUserId)).
+			attr("max_hops", hclInt(d.MaxHops))
+		if d.Exchange != "" {
+			definition.attr("exchange", hclString(d.Exchange))
+		}
+		if d.Queue != "" {
+			definition.attr("queue", hclString(d.Queue))
+		}
+		if d.Expires != 0 {
+			definition.attr("expires", hclInt(d.Expires))
+		}
+		if d.MessageTTL != 0 {
+			definition.attr("message_ttl", hclInt(int(d.MessageTTL)))
+		}
+	}
+}
+
+// writeConfig writes the resource blocks, and the import blocks when
+// imports is true.
+func (g *generator) writeConfig(w io.Writer, imports bool) {
+	for i, block := range g.blocks {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		for _, c := range g.comments[block] {
+			fmt.Fprintf(w, "# %s\n", c)
+		}
+		block.write(w, "")
+	}
+
+	if !imports {
+		return
+	}
+
+	for _, i := range g.imports {
+		fmt.Fprintln(w)
+		newBlock("import").
+			attr("to", i.address).
+			attr("id", hclString(i.id)).
+			write(w, "")
+	}
+}
+
+// writeImportScript writes a terraform import command for each resource.
+func (g *generator) writeImportScript(w io.Writer) {
+	fmt.Fprintln(w, "#!/bin/sh")
+	fmt.Fprintln(w, "set -e")
+	for _, i := range g.imports {
+		fmt.Fprintf(w, "terraform import %s %s\n", shellQuote(i.address), shellQuote(i.id))
+	}
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+)
+
+func TestHclString(t *testing.T) {
+	var inputs = []struct {
+		value    string
+		expected string
+	}{
+		{"plain", `"plain"`},
+		{`a "quoted" \ value`, `"a \"quoted\" \\ value"`},
+		{"line\nbreak\ttab", `"line\nbreak\ttab"`},
+		{"${var.x} %{if} $x %", `"$${var.x} %%{if} $x %"`},
+		{"\x01", `"\u0001"`},
+	}
+
+	for _, input := range inputs {
+		if actual := hclString(input.value); actual != input.expected {
+			t.Errorf("hclString(%q) = %s, expected %s", input.value, actual, input.expected)
+		}
+	}
+}
+
+func TestResourceName(t *testing.T) {
+	g := newGenerator()
+
+	var inputs = []struct {
+		vhost    string
+		parts    []string
+		expected string
+	}{
+		{"/", []string{"orders"}, "orders"},
+		{"prod", []string{"orders"}, "prod_orders"},
+		{"/", []string{"Orders.Created"}, "orders_created"},
+		{"/", []string{"orders"}, "orders_2"},
+		{"/", []string{"1st"}, "_1st"},
+		{"", []string{"/"}, "default"},
+	}
+
+	for _, input := range inputs {
+		if actual := g.resourceName("rabbitmq_queue", input.vhost, input.parts...); actual != input.expected {
+			t.Errorf("resourceName(%q, %q) = %s, expected %s", input.vhost, input.parts, actual, input.expected)
+		}
+	}
+}
+
+func TestBindingPropertiesKey(t *testing.T) {
+	var inputs = []struct {
+		binding  rabbithole.BindingInfo
+		expected string
+		ok       bool
+	}{
+		{rabbithole.BindingInfo{RoutingKey: ""}, "~", true},
+		{rabbithole.BindingInfo{RoutingKey: "orders.#"}, "orders.%23", true},
+		{rabbithole.BindingInfo{RoutingKey: "a b~c"}, "a+b%7Ec", true},
+		{rabbithole.BindingInfo{RoutingKey: "a", PropertiesKey: "a~hash"}, "a~hash", true},
+		{rabbithole.BindingInfo{RoutingKey: "a", Arguments: map[string]interface{}{"x": "y"}}, "", false},
+	}
+
+	for _, input := range inputs {
+		actual, ok := bindingPropertiesKey(input.binding)
+		if actual != input.expected || ok != input.ok {
+			t.Errorf("bindingPropertiesKey(%#v) = %q, %t, expected %q, %t", input.binding, actual, ok, input.expected, input.ok)
+		}
+	}
+}
+
+func TestPolicyDefinitionMap(t *testing.T) {
+	var inputs = []struct {
+		definition rabbithole.PolicyDefinition
+		ok         bool
+	}{
+		{rabbithole.PolicyDefinition{"queue-mode": "lazy", "message-ttl": float64(60000)}, true},
+		{rabbithole.PolicyDefinition{"max-length-bytes": "100"}, false},
+		{rabbithole.PolicyDefinition{"ha-promote-on-shutdown": true}, false},
+		{rabbithole.PolicyDefinition{"ha-params": []interface{}{"a", "b"}}, false},
+		{rabbithole.PolicyDefinition{"ratio": float64(0.5)}, false},
+	}
+
+	for _, input := range inputs {
+		if _, ok := policyDefinitionMap(input.definition); ok != input.ok {
+			t.Errorf("policyDefinitionMap(%#v) = %t, expected %t", input.definition, ok, input.ok)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	o := &objects{
+		Vhosts: []rabbithole.VhostInfo{{Name: "/"}},
+		Exchanges: []rabbithole.ExchangeInfo{
+			{Name: "", Vhost: "/", Type: "direct"},
+			{Name: "amq.topic", Vhost: "/", Type: "topic"},
+			{Name: "events", Vhost: "/", Type: "topic", Durable: true},
+		},
+		Queues: []rabbithole.QueueInfo{
+			{Name: "amq.gen-abc", Vhost: "/"},
+			{Name: "orders", Vhost: "/", Durable: true, Arguments: map[string]interface{}{"x-max-length": float64(10)}},
+		},
+		Bindings: []rabbithole.BindingInfo{
+			{Source: "", Vhost: "/", Destination: "orders", DestinationType: "queue", RoutingKey: "orders"},
+			{Source: "events", Vhost: "/", Destination: "orders", DestinationType: "queue", RoutingKey: "orders.#", PropertiesKey: "orders.%23"},
+		},
+	}
+
+	o.removeServerObjects()
+
+	g := newGenerator()
+	if err := g.generate(o); err != nil {
+		t.Fatal(err)
+	}
+
+	var config bytes.Buffer
+	g.writeConfig(&config, true)
+
+	expected := `resource "rabbitmq_vhost" "default" {
+  name = "/"
+}
+
+resource "rabbitmq_exchange" "events" {
+  name  = "events"
+  vhost = "/"
+
+  settings {
+    type        = "topic"
+    durable     = true
+    auto_delete = false
+  }
+}
+
+resource "rabbitmq_queue" "orders" {
+  name  = "orders"
+  vhost = "/"
+
+  settings {
+    durable        = true
+    auto_delete    = false
+    arguments_json = "{\"x-max-length\":10}"
+  }
+}
+
+resource "rabbitmq_binding" "events_orders" {
+  source           = "events"
+  vhost            = "/"
+  destination      = "orders"
+  destination_type = "queue"
+  routing_key      = "orders.#"
+}
+
+import {
+  to = rabbitmq_vhost.default
+  id = "/"
+}
+
+import {
+  to = rabbitmq_exchange.events
+  id = "events@/"
+}
+
+import {
+  to = rabbitmq_queue.orders
+  id = "orders@/"
+}
+
+import {
+  to = rabbitmq_binding.events_orders
+  id = "%2F/events/orders/queue/orders.%2523"
+}
+`
+
+	if config.String() != expected {
+		t.Errorf("Unexpected configuration:\n%s", config.String())
+	}
+
+	var script bytes.Buffer
+	g.writeImportScript(&script)
+	if !strings.Contains(script.String(), "terraform import 'rabbitmq_queue.orders' 'orders@/'\n") {
+		t.Errorf("Unexpected import script:\n%s", script.String())
+	}
+}
@@ -0,0 +1,242 @@
+// Command rabbitmq-terraform helps adopting the RabbitMQ provider on existing
+// brokers.
+//
+// The generate subcommand reads the objects of a broker, through its
+// management API or a definitions export, and writes the configuration of
+// the matching resources, with the imports of the resources either as import
+// blocks or as terraform import commands.
+//
+// The diff subcommand compares the objects of a broker, or of a definitions
+// export, with the objects managed by a Terraform configuration, without
+// needing the Terraform state.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"generate": {
+		summary: "generate the configuration of the objects of a broker",
+		run:     runGenerate,
+	},
+	"diff": {
+		summary: "compare the objects of a broker with a Terraform configuration",
+		run:     runDiff,
+	},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [options]\n\nCommands:\n", os.Args[0])
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].summary)
+	}
+}
+
+// brokerFlags are the flags selecting the broker or definitions export to
+// read.
+type brokerFlags struct {
+	endpoint    string
+	username    string
+	password    string
+	insecure    bool
+	definitions string
+	vhost       string
+}
+
+func (f *brokerFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&f.endpoint, "endpoint", os.Getenv("RABBITMQ_ENDPOINT"), "management API endpoint, defaults to $RABBITMQ_ENDPOINT")
+	flags.StringVar(&f.username, "username", os.Getenv("RABBITMQ_USERNAME"), "management API username, defaults to $RABBITMQ_USERNAME")
+	flags.StringVar(&f.password, "password", os.Getenv("RABBITMQ_PASSWORD"), "management API password, defaults to $RABBITMQ_PASSWORD")
+	flags.BoolVar(&f.insecure, "insecure", os.Getenv("RABBITMQ_INSECURE") == "true", "skip the verification of the certificate of the endpoint")
+	flags.StringVar(&f.definitions, "definitions", "", "read a definitions export instead of the management API, - for the standard input")
+	flags.StringVar(&f.vhost, "vhost", "", "only read the objects of this vhost, which is also the vhost of exports of a single vhost")
+}
+
+func (f *brokerFlags) load() (*objects, error) {
+	var o *objects
+	var err error
+	if f.definitions != "" {
+		o, err = loadDefinitions(f.definitions, f.vhost)
+	} else {
+		if f.endpoint == "" || f.username == "" || f.password == "" {
+			return nil, fmt.Errorf("-endpoint, -username and -password are required without -definitions")
+		}
+		o, err = loadBroker(f.endpoint, f.username, f.password, f.insecure)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	o.removeServerObjects()
+	if f.vhost != "" {
+		o.filterVhost(f.vhost)
+	}
+
+	return o, nil
+}
+
+func runGenerate(args []string) error {
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+
+	var broker brokerFlags
+	broker.register(flags)
+	importMode := flags.String("import-mode", importModeBlocks, "how to import the resources: blocks, for import blocks (Terraform 1.5+), or commands, for terraform import commands")
+	importScript := flags.String("import-script", "", "file the terraform import commands are written to, with -import-mode commands")
+	output := flags.String("o", "-", "file the configuration is written to, - for the standard output")
+	flags.Parse(args)
+
+	switch *importMode {
+	case importModeBlocks:
+	case importModeCommands:
+		if *importScript == "" {
+			return fmt.Errorf("-import-script is required with -import-mode %s", importModeCommands)
+		}
+	default:
+		return fmt.Errorf("-import-mode must be one of %s", strings.Join([]string{importModeBlocks, importModeCommands}, ", "))
+	}
+
+	o, err := broker.load()
+	if err != nil {
+		return err
+	}
+
+	g := newGenerator()
+	if err := g.generate(o); err != nil {
+		return err
+	}
+
+	var config bytes.Buffer
+	g.writeConfig(&config, *importMode == importModeBlocks)
+	if err := writeOutput(*output, config.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	if *importMode == importModeCommands {
+		var script bytes.Buffer
+		g.writeImportScript(&script)
+		if err := writeOutput(*importScript, script.Bytes(), 0755); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// varFlags are the values of -var flags, as name=value.
+type varFlags map[string]string
+
+func (v varFlags) String() string {
+	return fmt.Sprint(map[string]string(v))
+}
+
+func (v varFlags) Set(s string) error {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("%q is not a name=value pair", s)
+	}
+	v[parts[0]] = parts[1]
+	return nil
+}
+
+func runDiff(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+
+	var broker brokerFlags
+	broker.register(flags)
+	vars := varFlags{}
+	flags.Var(vars, "var", "value of a variable of the configuration, as name=value, may be repeated")
+	config := flags.String("config", ".", "directory of the Terraform configuration")
+	format := flags.String("format", diffFormatText, "format of the report: text or json")
+	output := flags.String("o", "-", "file the report is written to, - for the standard output")
+	detailedExitCode := flags.Bool("detailed-exitcode", false, "exit with 2 when there are differences, like terraform plan")
+	flags.Parse(args)
+
+	if *format != diffFormatText && *format != diffFormatJson {
+		return fmt.Errorf("-format must be one of %s", strings.Join([]string{diffFormatText, diffFormatJson}, ", "))
+	}
+
+	current, err := broker.load()
+	if err != nil {
+		return err
+	}
+
+	desired, warnings, err := loadConfig(*config, vars)
+	if err != nil {
+		return err
+	}
+	if broker.vhost != "" {
+		desired.filterVhost(broker.vhost)
+	}
+
+	report, err := diffObjects(current, desired)
+	if err != nil {
+		return err
+	}
+	report.Warnings = warnings
+
+	var out bytes.Buffer
+	if *format == diffFormatJson {
+		err = report.writeJson(&out)
+	} else {
+		err = report.writeText(&out)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := writeOutput(*output, out.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	if *detailedExitCode && !report.empty() {
+		os.Exit(2)
+	}
+
+	return nil
+}
+
+func writeOutput(path string, data []byte, perm os.FileMode) error {
+	if path == "-" {
+		_, err := io.Copy(os.Stdout, bytes.NewReader(data))
+		return err
+	}
+	return ioutil.WriteFile(path, data, perm)
+}
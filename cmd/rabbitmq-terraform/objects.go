@@ -0,0 +1,480 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+)
+
+// objects holds the objects of a broker that the provider can manage.
+type objects struct {
+	Vhosts              []rabbithole.VhostInfo
+	Users               []rabbithole.UserInfo
+	Permissions         []rabbithole.PermissionInfo
+	TopicPermissions    []rabbithole.TopicPermissionInfo
+	Exchanges           []rabbithole.ExchangeInfo
+	Queues              []rabbithole.QueueInfo
+	Bindings            []rabbithole.BindingInfo
+	Policies            []rabbithole.Policy
+	Shovels             []rabbithole.ShovelInfo
+	FederationUpstreams []rabbithole.FederationUpstream
+
+	// kinds are the kinds of objects that were read, nil when every kind
+	// was, e.g. exports of a single vhost have no users.
+	kinds map[string]bool
+
+	// rotations are the users of the rabbitmq_user_password_rotation
+	// resources of a configuration, whose names are only known once they are
+	// compared with a broker.
+	rotations []userRotation
+}
+
+// userRotation holds the settings of the users of a password rotation, which
+// are named after the generation of their password, e.g. app-2. The user of
+// the permissions is empty.
+type userRotation struct {
+	name        string
+	tags        string
+	permissions []rabbithole.PermissionInfo
+}
+
+func (o *objects) hasKind(kind string) bool {
+	return o.kinds == nil || o.kinds[kind]
+}
+
+// loadBroker lists the objects of a broker through the management API.
+func loadBroker(endpoint, username, password string, insecure bool) (*objects, error) {
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+		Proxy:           http.ProxyFromEnvironment,
+	}
+
+	rmqc, err := rabbithole.NewTLSClient(endpoint, username, password, transport)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &objects{}
+	if o.Vhosts, err = rmqc.ListVhosts(); err != nil {
+		return nil, fmt.Errorf("Error listing vhosts: %s", err)
+	}
+	if o.Users, err = rmqc.ListUsers(); err != nil {
+		return nil, fmt.Errorf("Error listing users: %s", err)
+	}
+	if o.Permissions, err = rmqc.ListPermissions(); err != nil {
+		return nil, fmt.Errorf("Error listing permissions: %s", err)
+	}
+	if o.Exchanges, err = rmqc.ListExchanges(); err != nil {
+		return nil, fmt.Errorf("Error listing exchanges: %s", err)
+	}
+	if o.Queues, err = rmqc.ListQueues(); err != nil {
+		return nil, fmt.Errorf("Error listing queues: %s", err)
+	}
+	if o.Bindings, err = rmqc.ListBindings(); err != nil {
+		return nil, fmt.Errorf("Error listing bindings: %s", err)
+	}
+	if o.Policies, err = rmqc.ListPolicies(); err != nil {
+		return nil, fmt.Errorf("Error listing policies: %s", err)
+	}
+
+	// Topic permissions need RabbitMQ 3.7, and shovels and federation
+	// upstreams their plugins.
+	if o.TopicPermissions, err = rmqc.ListTopicPermissions(); err != nil && !notFound(err) {
+		return nil, fmt.Errorf("Error listing topic permissions: %s", err)
+	}
+	if o.Shovels, err = rmqc.ListShovels(); err != nil && !notFound(err) {
+		return nil, fmt.Errorf("Error listing shovels: %s", err)
+	}
+	if o.FederationUpstreams, err = rmqc.ListFederationUpstreams(); err != nil && !notFound(err) {
+		return nil, fmt.Errorf("Error listing federation upstreams: %s", err)
+	}
+
+	return o, nil
+}
+
+func notFound(err error) bool {
+	rmqErr, ok := err.(rabbithole.ErrorResponse)
+	return ok && (rmqErr.StatusCode == 404 || rmqErr.StatusCode == 400)
+}
+
+// definitionsDocument is the format of /api/definitions exports. The
+// objects of exports of a single vhost have no vhost.
+type definitionsDocument struct {
+	Vhosts           []rabbithole.VhostInfo           `json:"vhosts"`
+	Users            []definitionsUser                `json:"users"`
+	Permissions      []rabbithole.PermissionInfo      `json:"permissions"`
+	TopicPermissions []rabbithole.TopicPermissionInfo `json:"topic_permissions"`
+	Parameters       []definitionsParameter           `json:"parameters"`
+	Policies         []rabbithole.Policy              `json:"policies"`
+	Queues           []rabbithole.QueueInfo           `json:"queues"`
+	Exchanges        []rabbithole.ExchangeInfo        `json:"exchanges"`
+	Bindings         []rabbithole.BindingInfo         `json:"bindings"`
+}
+
+// Tags are a comma separated string before RabbitMQ 3.12, and a list since.
+type definitionsUser struct {
+	Name             string                      `json:"name"`
+	PasswordHash     string                      `json:"password_hash"`
+	HashingAlgorithm rabbithole.HashingAlgorithm `json:"hashing_algorithm"`
+	Tags             json.RawMessage             `json:"tags"`
+}
+
+type definitionsParameter struct {
+	Component string                 `json:"component"`
+	Vhost     string                 `json:"vhost"`
+	Name      string                 `json:"name"`
+	Value     map[string]interface{} `json:"value"`
+}
+
+// loadDefinitions reads a definitions export, "-" being the standard input.
+// vhost is the vhost of exports of a single vhost.
+func loadDefinitions(path string, vhost string) (*objects, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parseDefinitions(data, path, vhost)
+}
+
+// parseDefinitions parses a definitions document, source describing where it
+// comes from in errors.
+func parseDefinitions(data []byte, source string, vhost string) (*objects, error) {
+	var doc definitionsDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("Unable to parse definitions %s: %s", source, err)
+	}
+
+	o := &objects{
+		Vhosts:           doc.Vhosts,
+		Permissions:      doc.Permissions,
+		TopicPermissions: doc.TopicPermissions,
+		Policies:         doc.Policies,
+		Queues:           doc.Queues,
+		Exchanges:        doc.Exchanges,
+		Bindings:         doc.Bindings,
+	}
+
+	o.kinds = map[string]bool{
+		"vhost":               doc.Vhosts != nil,
+		"user":                doc.Users != nil,
+		"permissions":         doc.Permissions != nil,
+		"topic_permissions":   doc.TopicPermissions != nil,
+		"exchange":            doc.Exchanges != nil,
+		"queue":               doc.Queues != nil,
+		"binding":             doc.Bindings != nil,
+		"policy":              doc.Policies != nil,
+		"shovel":              doc.Parameters != nil,
+		"federation_upstream": doc.Parameters != nil,
+	}
+
+	for _, user := range doc.Users {
+		tags, err := definitionsUserTags(user.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse tags of user %s: %s", user.Name, err)
+		}
+		o.Users = append(o.Users, rabbithole.UserInfo{
+			Name:             user.Name,
+			PasswordHash:     user.PasswordHash,
+			HashingAlgorithm: user.HashingAlgorithm,
+			Tags:             tags,
+		})
+	}
+
+	for _, parameter := range doc.Parameters {
+		switch parameter.Component {
+		case "shovel":
+			var definition rabbithole.ShovelDefinition
+			if err := decodeParameterValue(parameter, &definition); err != nil {
+				return nil, err
+			}
+			o.Shovels = append(o.Shovels, rabbithole.ShovelInfo{
+				Name:       parameter.Name,
+				Vhost:      parameter.Vhost,
+				Component:  parameter.Component,
+				Definition: definition,
+			})
+		case "federation-upstream":
+			var definition rabbithole.FederationDefinition
+			if err := decodeParameterValue(parameter, &definition); err != nil {
+				return nil, err
+			}
+			o.FederationUpstreams = append(o.FederationUpstreams, rabbithole.FederationUpstream{
+				Name:       parameter.Name,
+				Vhost:      parameter.Vhost,
+				Component:  parameter.Component,
+				Definition: definition,
+			})
+		}
+	}
+
+	if vhost != "" {
+		o.setVhost(vhost)
+	}
+
+	return o, nil
+}
+
+func definitionsUserTags(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+
+	var tags string
+	if err := json.Unmarshal(raw, &tags); err == nil {
+		return tags, nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return "", err
+	}
+
+	return strings.Join(list, ","), nil
+}
+
+// decodeParameterValue decodes the value of a runtime parameter into the
+// definition of a rabbit-hole type. Exports may hold values that the provider
+// represents as strings, e.g. a number for delete-after, and lists of URIs,
+// which the provider only supports with a single URI.
+func decodeParameterValue(parameter definitionsParameter, definition interface{}) error {
+	value := make(map[string]interface{}, len(parameter.Value))
+	for key, v := range parameter.Value {
+		switch x := v.(type) {
+		case []interface{}:
+			if len(x) != 1 {
+				return fmt.Errorf("%s %s in vhost %s has %d values for %s, only one is supported", parameter.Component, parameter.Name, parameter.Vhost, len(x), key)
+			}
+			v = x[0]
+		case float64:
+			if strings.HasSuffix(key, "delete-after") {
+				v = fmt.Sprint(x)
+			}
+		}
+		value[key] = v
+	}
+
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(bytes, definition); err != nil {
+		return fmt.Errorf("Unable to parse %s %s in vhost %s: %s", parameter.Component, parameter.Name, parameter.Vhost, err)
+	}
+
+	return nil
+}
+
+// setVhost sets the vhost of the objects that have none.
+func (o *objects) setVhost(vhost string) {
+	for i := range o.Permissions {
+		if o.Permissions[i].Vhost == "" {
+			o.Permissions[i].Vhost = vhost
+		}
+	}
+	for i := range o.TopicPermissions {
+		if o.TopicPermissions[i].Vhost == "" {
+			o.TopicPermissions[i].Vhost = vhost
+		}
+	}
+	for i := range o.Exchanges {
+		if o.Exchanges[i].Vhost == "" {
+			o.Exchanges[i].Vhost = vhost
+		}
+	}
+	for i := range o.Queues {
+		if o.Queues[i].Vhost == "" {
+			o.Queues[i].Vhost = vhost
+		}
+	}
+	for i := range o.Bindings {
+		if o.Bindings[i].Vhost == "" {
+			o.Bindings[i].Vhost = vhost
+		}
+	}
+	for i := range o.Policies {
+		if o.Policies[i].Vhost == "" {
+			o.Policies[i].Vhost = vhost
+		}
+	}
+	for i := range o.Shovels {
+		if o.Shovels[i].Vhost == "" {
+			o.Shovels[i].Vhost = vhost
+		}
+	}
+	for i := range o.FederationUpstreams {
+		if o.FederationUpstreams[i].Vhost == "" {
+			o.FederationUpstreams[i].Vhost = vhost
+		}
+	}
+}
+
+// removeServerObjects removes the objects declared by RabbitMQ itself: the
+// default and amq.* exchanges, server named queues, and the bindings of the
+// default exchange.
+func (o *objects) removeServerObjects() {
+	exchanges := o.Exchanges[:0]
+	for _, e := range o.Exchanges {
+		if e.Name != "" && !strings.HasPrefix(e.Name, "amq.") {
+			exchanges = append(exchanges, e)
+		}
+	}
+	o.Exchanges = exchanges
+
+	queues := o.Queues[:0]
+	for _, q := range o.Queues {
+		if !strings.HasPrefix(q.Name, "amq.gen-") {
+			queues = append(queues, q)
+		}
+	}
+	o.Queues = queues
+
+	bindings := o.Bindings[:0]
+	for _, b := range o.Bindings {
+		if b.Source != "" {
+			bindings = append(bindings, b)
+		}
+	}
+	o.Bindings = bindings
+}
+
+// filterVhost only keeps the objects of a vhost. Users are kept when they
+// have permissions in the vhost.
+func (o *objects) filterVhost(vhost string) {
+	filtered := &objects{kinds: o.kinds}
+
+	for _, v := range o.Vhosts {
+		if v.Name == vhost {
+			filtered.Vhosts = append(filtered.Vhosts, v)
+		}
+	}
+
+	users := map[string]bool{}
+	for _, p := range o.Permissions {
+		if p.Vhost == vhost {
+			filtered.Permissions = append(filtered.Permissions, p)
+			users[p.User] = true
+		}
+	}
+	for _, p := range o.TopicPermissions {
+		if p.Vhost == vhost {
+			filtered.TopicPermissions = append(filtered.TopicPermissions, p)
+			users[p.User] = true
+		}
+	}
+	for _, u := range o.Users {
+		if users[u.Name] {
+			filtered.Users = append(filtered.Users, u)
+		}
+	}
+	for _, r := range o.rotations {
+		var permissions []rabbithole.PermissionInfo
+		for _, p := range r.permissions {
+			if p.Vhost == vhost {
+				permissions = append(permissions, p)
+			}
+		}
+		if len(permissions) > 0 {
+			r.permissions = permissions
+			filtered.rotations = append(filtered.rotations, r)
+		}
+	}
+
+	for _, e := range o.Exchanges {
+		if e.Vhost == vhost {
+			filtered.Exchanges = append(filtered.Exchanges, e)
+		}
+	}
+	for _, q := range o.Queues {
+		if q.Vhost == vhost {
+			filtered.Queues = append(filtered.Queues, q)
+		}
+	}
+	for _, b := range o.Bindings {
+		if b.Vhost == vhost {
+			filtered.Bindings = append(filtered.Bindings, b)
+		}
+	}
+	for _, p := range o.Policies {
+		if p.Vhost == vhost {
+			filtered.Policies = append(filtered.Policies, p)
+		}
+	}
+	for _, s := range o.Shovels {
+		if s.Vhost == vhost {
+			filtered.Shovels = append(filtered.Shovels, s)
+		}
+	}
+	for _, f := range o.FederationUpstreams {
+		if f.Vhost == vhost {
+			filtered.FederationUpstreams = append(filtered.FederationUpstreams, f)
+		}
+	}
+
+	*o = *filtered
+}
+
+// add adds the objects of other, e.g. the objects declared by a definitions
+// document of a configuration.
+func (o *objects) add(other *objects) {
+	o.Vhosts = append(o.Vhosts, other.Vhosts...)
+	o.Users = append(o.Users, other.Users...)
+	o.Permissions = append(o.Permissions, other.Permissions...)
+	o.TopicPermissions = append(o.TopicPermissions, other.TopicPermissions...)
+	o.Exchanges = append(o.Exchanges, other.Exchanges...)
+	o.Queues = append(o.Queues, other.Queues...)
+	o.Bindings = append(o.Bindings, other.Bindings...)
+	o.Policies = append(o.Policies, other.Policies...)
+	o.Shovels = append(o.Shovels, other.Shovels...)
+	o.FederationUpstreams = append(o.FederationUpstreams, other.FederationUpstreams...)
+	o.rotations = append(o.rotations, other.rotations...)
+}
+
+// expandRotations replaces the password rotations by their users: the users
+// of current named after a generation of the rotation, or the user of the
+// first generation when there is none yet.
+func (o *objects) expandRotations(current *objects) {
+	for _, r := range o.rotations {
+		generation := regexp.MustCompile("^" + regexp.QuoteMeta(r.name) + "-[0-9]+$")
+
+		// Exports of a single vhost only name users in permissions.
+		names := map[string]bool{}
+		for _, u := range current.Users {
+			if generation.MatchString(u.Name) {
+				names[u.Name] = true
+			}
+		}
+		for _, p := range current.Permissions {
+			if generation.MatchString(p.User) {
+				names[p.User] = true
+			}
+		}
+		if len(names) == 0 {
+			names[r.name+"-1"] = true
+		}
+
+		for name := range names {
+			o.Users = append(o.Users, rabbithole.UserInfo{Name: name, Tags: r.tags})
+			for _, p := range r.permissions {
+				p.User = name
+				o.Permissions = append(o.Permissions, p)
+			}
+		}
+	}
+
+	o.rotations = nil
+}
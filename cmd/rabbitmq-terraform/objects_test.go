@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadDefinitions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rabbitmq-terraform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "definitions.json")
+	definitions := `{
+  "users": [
+    {"name": "a", "password_hash": "hash", "tags": "administrator,management"},
+    {"name": "b", "password_hash": "hash", "tags": ["management"]}
+  ],
+  "queues": [{"name": "orders", "durable": true, "arguments": {}}],
+  "parameters": [
+    {"component": "shovel", "name": "move", "value": {"src-uri": ["amqp://a"], "src-queue": "a", "dest-uri": "amqp://b", "src-delete-after": 5}},
+    {"component": "federation-upstream", "name": "up", "value": {"uri": "amqp://c", "max-hops": 2}}
+  ]
+}`
+	if err := ioutil.WriteFile(path, []byte(definitions), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := loadDefinitions(path, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags := []string{o.Users[0].Tags, o.Users[1].Tags}
+	if !reflect.DeepEqual(tags, []string{"administrator,management", "management"}) {
+		t.Errorf("Unexpected user tags %#v", tags)
+	}
+
+	if o.Queues[0].Vhost != "prod" {
+		t.Errorf("Unexpected queue vhost %q", o.Queues[0].Vhost)
+	}
+
+	shovel := o.Shovels[0]
+	if shovel.Vhost != "prod" || shovel.Definition.SourceURI != "amqp://a" || shovel.Definition.SourceDeleteAfter != "5" {
+		t.Errorf("Unexpected shovel %#v", shovel)
+	}
+
+	upstream := o.FederationUpstreams[0]
+	if upstream.Definition.Uri != "amqp://c" || upstream.Definition.MaxHops != 2 {
+		t.Errorf("Unexpected federation upstream %#v", upstream)
+	}
+}
+
+func TestDecodeParameterValueMultipleUris(t *testing.T) {
+	var definition struct {
+		SourceURI string `json:"src-uri"`
+	}
+
+	parameter := definitionsParameter{
+		Component: "shovel",
+		Vhost:     "/",
+		Name:      "move",
+		Value:     map[string]interface{}{"src-uri": []interface{}{"amqp://a", "amqp://b"}},
+	}
+
+	if err := decodeParameterValue(parameter, &definition); err == nil {
+		t.Errorf("Expected an error for several URIs")
+	}
+}
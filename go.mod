@@ -1,8 +1,10 @@
 module github.com/terraform-providers/terraform-provider-rabbitmq
 
 require (
+	github.com/hashicorp/hcl2 v0.0.0-20190821123243-0c888d1241f6
 	github.com/hashicorp/terraform-plugin-sdk v1.0.0
 	github.com/michaelklishin/rabbit-hole/v2 v2.4.0
+	github.com/zclconf/go-cty v1.1.0
 )
 
 go 1.14
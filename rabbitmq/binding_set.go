@@ -0,0 +1,150 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+// bindingIgnored returns true for bindings that can't be managed, i.e. the
+// implicit bindings of queues to the default exchange.
+func bindingIgnored(binding rabbithole.BindingInfo) bool {
+	return binding.Source == ""
+}
+
+// bindingArgumentsToMap converts the arguments of a binding read from
+// RabbitMQ to the string values of the arguments attribute.
+func bindingArgumentsToMap(arguments map[string]interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, len(arguments))
+	for key, value := range arguments {
+		if s, ok := value.(string); ok {
+			m[key] = s
+			continue
+		}
+		bytes, err := json.Marshal(value)
+		if err != nil {
+			m[key] = fmt.Sprint(value)
+			continue
+		}
+		m[key] = string(bytes)
+	}
+	return m
+}
+
+// bindingSetKey identifies a binding by everything but its properties key,
+// which RabbitMQ computes from the routing key and the arguments.
+func bindingSetKey(binding rabbithole.BindingInfo) string {
+	arguments, _ := json.Marshal(bindingArgumentsToMap(binding.Arguments))
+	return fmt.Sprintf("%q %q %q %q %s", binding.Source, binding.Destination, binding.DestinationType, binding.RoutingKey, arguments)
+}
+
+// bindingsFromSet returns the bindings of a binding set attribute, template
+// holding the attributes shared by every binding of the set.
+func bindingsFromSet(set *schema.Set, template rabbithole.BindingInfo) []rabbithole.BindingInfo {
+	bindings := make([]rabbithole.BindingInfo, 0, set.Len())
+	for _, v := range set.List() {
+		m := v.(map[string]interface{})
+
+		binding := template
+		if source, ok := m["source"].(string); ok {
+			binding.Source = source
+		}
+		if destination, ok := m["destination"].(string); ok {
+			binding.Destination = destination
+		}
+		if destinationType, ok := m["destination_type"].(string); ok {
+			binding.DestinationType = destinationType
+		}
+		binding.RoutingKey = m["routing_key"].(string)
+		binding.Arguments = m["arguments"].(map[string]interface{})
+
+		bindings = append(bindings, binding)
+	}
+	return bindings
+}
+
+// reconcileBindings declares the desired bindings that are missing, then
+// deletes the actual bindings that aren't desired, so that messages stay
+// routable while the bindings change.
+func reconcileBindings(rmqc *rabbitmqClient, vhost string, desired, actual []rabbithole.BindingInfo) error {
+	existing := map[string]bool{}
+	for _, binding := range actual {
+		existing[bindingSetKey(binding)] = true
+	}
+
+	wanted := map[string]bool{}
+	for _, binding := range desired {
+		key := bindingSetKey(binding)
+		wanted[key] = true
+		if existing[key] {
+			continue
+		}
+
+		if _, err := declareBinding(rmqc, vhost, binding); err != nil {
+			return err
+		}
+	}
+
+	for _, binding := range actual {
+		if bindingIgnored(binding) || wanted[bindingSetKey(binding)] {
+			continue
+		}
+
+		log.Printf("[DEBUG] RabbitMQ: Removing unmanaged binding: %#v", binding)
+
+		binding.Vhost = vhost
+		if err := deleteBinding(rmqc, binding); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteBindings deletes the actual bindings that are managed.
+func deleteBindings(rmqc *rabbitmqClient, vhost string, managed, actual []rabbithole.BindingInfo) error {
+	keys := map[string]bool{}
+	for _, binding := range managed {
+		keys[bindingSetKey(binding)] = true
+	}
+
+	for _, binding := range actual {
+		if !keys[bindingSetKey(binding)] {
+			continue
+		}
+
+		binding.Vhost = vhost
+		if err := deleteBinding(rmqc, binding); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// bindingSetElem returns the schema of a binding of a binding set, keys being
+// the attributes identifying the other end of the binding.
+func bindingSetElem(keys map[string]*schema.Schema) *schema.Resource {
+	s := map[string]*schema.Schema{
+		"routing_key": {
+			Type:     schema.TypeString,
+			Optional: true,
+		},
+
+		"arguments": {
+			Type:     schema.TypeMap,
+			Optional: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		},
+	}
+
+	for key, value := range keys {
+		s[key] = value
+	}
+
+	return &schema.Resource{Schema: s}
+}
@@ -0,0 +1,62 @@
+package rabbitmq
+
+import (
+	"reflect"
+	"testing"
+
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+)
+
+func TestBindingArgumentsToMap(t *testing.T) {
+	arguments := bindingArgumentsToMap(map[string]interface{}{
+		"x-match": "all",
+		"count":   float64(5),
+		"enabled": true,
+		"nodes":   []interface{}{"a", "b"},
+	})
+
+	expected := map[string]interface{}{
+		"x-match": "all",
+		"count":   "5",
+		"enabled": "true",
+		"nodes":   `["a","b"]`,
+	}
+
+	if !reflect.DeepEqual(arguments, expected) {
+		t.Errorf("bindingArgumentsToMap failed. Got %#v", arguments)
+	}
+}
+
+func TestBindingSetKey(t *testing.T) {
+	binding := rabbithole.BindingInfo{
+		Source:          "test",
+		Destination:     "a",
+		DestinationType: "queue",
+		RoutingKey:      "orders.#",
+		Arguments:       map[string]interface{}{"count": "5"},
+	}
+
+	// Arguments read from RabbitMQ keep their type, while arguments of the
+	// configuration are strings.
+	read := binding
+	read.Vhost = "test"
+	read.PropertiesKey = "orders.%23~abc"
+	read.Arguments = map[string]interface{}{"count": float64(5)}
+
+	if bindingSetKey(binding) != bindingSetKey(read) {
+		t.Errorf("bindingSetKey should match for: %#v and %#v", binding, read)
+	}
+
+	var different = []rabbithole.BindingInfo{
+		{Source: "test", Destination: "b", DestinationType: "queue", RoutingKey: "orders.#", Arguments: map[string]interface{}{"count": "5"}},
+		{Source: "test", Destination: "a", DestinationType: "exchange", RoutingKey: "orders.#", Arguments: map[string]interface{}{"count": "5"}},
+		{Source: "test", Destination: "a", DestinationType: "queue", RoutingKey: "orders.*", Arguments: map[string]interface{}{"count": "5"}},
+		{Source: "test", Destination: "a", DestinationType: "queue", RoutingKey: "orders.#"},
+	}
+
+	for _, other := range different {
+		if bindingSetKey(binding) == bindingSetKey(other) {
+			t.Errorf("bindingSetKey should differ for: %#v", other)
+		}
+	}
+}
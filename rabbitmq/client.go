@@ -0,0 +1,159 @@
+package rabbitmq
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+)
+
+// rabbitmqClient is the provider meta shared by all resources. It embeds the
+// rabbit-hole client and remembers details about the broker it talks to.
+type rabbitmqClient struct {
+	*rabbithole.Client
+
+	// Version of the broker, detected once when the provider is configured.
+	version serverVersion
+
+	// Transport shared with the rabbit-hole client, for the endpoints of the
+	// management API that rabbit-hole doesn't cover.
+	transport http.RoundTripper
+}
+
+func newRabbitmqClient(rmqc *rabbithole.Client, transport http.RoundTripper) (*rabbitmqClient, error) {
+	overview, err := rmqc.Overview()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to retrieve RabbitMQ server version: %s", err)
+	}
+
+	version, err := parseServerVersion(overview.RabbitMQVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rabbitmqClient{
+		Client:    rmqc,
+		version:   version,
+		transport: transport,
+	}, nil
+}
+
+// executeRequest sends a request to the management API, path being relative
+// to /api/. Error responses are returned as rabbithole.ErrorResponse, like
+// rabbit-hole does, so that they work with checkDeleted.
+func (c *rabbitmqClient) executeRequest(method string, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.Endpoint, "/")+"/api/"+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Close = true
+	req.SetBasicAuth(c.Username, c.Password)
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+
+	httpc := &http.Client{Transport: c.transport}
+	resp, err := httpc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 && !(method == "DELETE" && resp.StatusCode == 404) {
+		defer resp.Body.Close()
+		rme := rabbithole.ErrorResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&rme); err != nil {
+			rme.Message = fmt.Sprintf("Error %d from RabbitMQ: %s", resp.StatusCode, err)
+		}
+		rme.StatusCode = resp.StatusCode
+		return nil, rme
+	}
+
+	return resp, nil
+}
+
+// executeAndParseRequest sends a GET request to the management API and decodes
+// the JSON response into rec.
+func (c *rabbitmqClient) executeAndParseRequest(path string, rec interface{}) error {
+	resp, err := c.executeRequest("GET", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return json.NewDecoder(resp.Body).Decode(rec)
+}
+
+// requireVersion returns an error if the broker is older than
+// major.minor.patch.
+func (c *rabbitmqClient) requireVersion(feature string, major, minor, patch int) error {
+	if c.version.atLeast(major, minor, patch) {
+		return nil
+	}
+
+	return fmt.Errorf("%s requires RabbitMQ >= %d.%d.%d, connected to %s", feature, major, minor, patch, c.version)
+}
+
+type serverVersion struct {
+	major, minor, patch int
+	raw                 string
+}
+
+// parseServerVersion parses versions reported by the broker, such as
+// "3.8.9" or "3.9.0-rc.1". Development builds report "0.0.0" and are
+// treated as supporting every feature.
+func parseServerVersion(s string) (serverVersion, error) {
+	v := serverVersion{raw: s}
+
+	core := strings.SplitN(s, "-", 2)[0]
+	parts := strings.Split(core, ".")
+	if len(parts) < 2 {
+		return v, fmt.Errorf("Unable to parse RabbitMQ version: %s", s)
+	}
+
+	numbers := make([]int, 3)
+	for i := 0; i < len(parts) && i < 3; i++ {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return v, fmt.Errorf("Unable to parse RabbitMQ version: %s", s)
+		}
+		numbers[i] = n
+	}
+	v.major, v.minor, v.patch = numbers[0], numbers[1], numbers[2]
+
+	return v, nil
+}
+
+func (v serverVersion) isDevelopment() bool {
+	return v.major == 0 && v.minor == 0 && v.patch == 0
+}
+
+func (v serverVersion) atLeast(major, minor, patch int) bool {
+	if v.isDevelopment() {
+		return true
+	}
+	if v.major != major {
+		return v.major > major
+	}
+	if v.minor != minor {
+		return v.minor > minor
+	}
+	return v.patch >= patch
+}
+
+func (v serverVersion) String() string {
+	return v.raw
+}
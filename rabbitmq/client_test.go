@@ -0,0 +1,43 @@
+package rabbitmq
+
+import "testing"
+
+func TestParseServerVersion(t *testing.T) {
+	var badInputs = []string{
+		"",
+		"3",
+		"three.eight",
+	}
+
+	for _, input := range badInputs {
+		if _, err := parseServerVersion(input); err == nil {
+			t.Errorf("parseServerVersion failed for: %s.", input)
+		}
+	}
+
+	var goodInputs = []struct {
+		input               string
+		major, minor, patch int
+		atLeast37           bool
+		atLeast38           bool
+		atLeast3810         bool
+	}{
+		{"3.6.16", 3, 6, 16, false, false, false},
+		{"3.7.0", 3, 7, 0, true, false, false},
+		{"3.8.9", 3, 8, 9, true, true, false},
+		{"3.8.10", 3, 8, 10, true, true, true},
+		{"3.9.0-rc.1", 3, 9, 0, true, true, true},
+		{"4.0", 4, 0, 0, true, true, true},
+		{"0.0.0", 0, 0, 0, true, true, true},
+	}
+
+	for _, test := range goodInputs {
+		v, err := parseServerVersion(test.input)
+		if err != nil || v.major != test.major || v.minor != test.minor || v.patch != test.patch {
+			t.Errorf("parseServerVersion failed for: %s.", test.input)
+		}
+		if v.atLeast(3, 7, 0) != test.atLeast37 || v.atLeast(3, 8, 0) != test.atLeast38 || v.atLeast(3, 8, 10) != test.atLeast3810 {
+			t.Errorf("atLeast failed for: %s.", test.input)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func dataSourceDefinitions() *schema.Resource {
+	return &schema.Resource{
+		Read: ReadDefinitionsExport,
+
+		Schema: map[string]*schema.Schema{
+			"vhost": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"definitions": {
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
+		},
+	}
+}
+
+func ReadDefinitionsExport(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	vhost := d.Get("vhost").(string)
+
+	definitions, err := getDefinitions(rmqc, vhost)
+	if err != nil {
+		return fmt.Errorf("Error retrieving RabbitMQ definitions: %s", err)
+	}
+
+	bytes, err := json.Marshal(normaliseDefinitions(definitions, vhost))
+	if err != nil {
+		return fmt.Errorf("could not encode definitions as JSON: %w", err)
+	}
+
+	d.SetId(definitionsId(vhost))
+	d.Set("definitions", string(bytes))
+
+	return nil
+}
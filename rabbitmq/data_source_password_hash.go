@@ -0,0 +1,58 @@
+package rabbitmq
+
+import (
+	"strconv"
+
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/hashcode"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+)
+
+func dataSourcePasswordHash() *schema.Resource {
+	return &schema.Resource{
+		Read: ReadPasswordHash,
+
+		Schema: map[string]*schema.Schema{
+			"password": {
+				Type:      schema.TypeString,
+				Required:  true,
+				Sensitive: true,
+			},
+
+			"salt": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringLenBetween(4, 4),
+			},
+
+			"hashing_algorithm": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      rabbithole.HashingAlgorithmSHA256.String(),
+				ValidateFunc: validation.StringInSlice(userHashingAlgorithms, false),
+			},
+
+			"password_hash": {
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
+		},
+	}
+}
+
+func ReadPasswordHash(d *schema.ResourceData, meta interface{}) error {
+	algorithm := rabbithole.HashingAlgorithm(d.Get("hashing_algorithm").(string))
+
+	hash, err := hashPassword(d.Get("password").(string), d.Get("salt").(string), algorithm)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(strconv.Itoa(hashcode.String(hash)))
+	d.Set("password_hash", hash)
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func dataSourcePolicyMatches() *schema.Resource {
+	return &schema.Resource{
+		Read: ReadPolicyMatches,
+
+		Schema: map[string]*schema.Schema{
+			"vhost": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"policy": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"queues": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+
+			"exchanges": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+
+			"effective_queues": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+
+			"effective_exchanges": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+func ReadPolicyMatches(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	vhost := d.Get("vhost").(string)
+	name := d.Get("policy").(string)
+
+	policy, err := rmqc.GetPolicy(vhost, name)
+	if err != nil {
+		return fmt.Errorf("Error retrieving RabbitMQ policy %s: %s", name, err)
+	}
+
+	pattern, err := regexp.Compile(policy.Pattern)
+	if err != nil {
+		return fmt.Errorf("Unable to evaluate the pattern of policy %s: %s", name, err)
+	}
+
+	targets := policyApplyTo[policy.ApplyTo]
+	matches := map[string][]string{}
+	effective := map[string][]string{}
+	for _, kind := range []string{"queues", "exchanges"} {
+		objects, err := listPolicyTargets(rmqc, kind, vhost)
+		if err != nil {
+			return err
+		}
+
+		matches[kind] = []string{}
+		effective[kind] = []string{}
+		for _, object := range objects {
+			// Policies never apply to the default exchange.
+			if kind == "exchanges" && object.Name == "" {
+				continue
+			}
+
+			if !pattern.MatchString(object.Name) || !policyTargetSelected(targets, kind, object.Type) {
+				continue
+			}
+
+			matches[kind] = append(matches[kind], object.Name)
+			if object.Policy == name {
+				effective[kind] = append(effective[kind], object.Name)
+			}
+		}
+
+		sort.Strings(matches[kind])
+		sort.Strings(effective[kind])
+	}
+
+	log.Printf("[DEBUG] RabbitMQ: Objects matched by policy %s: %#v", name, matches)
+
+	d.SetId(ResourceId(name, vhost))
+	d.Set("queues", matches["queues"])
+	d.Set("exchanges", matches["exchanges"])
+	d.Set("effective_queues", effective["queues"])
+	d.Set("effective_exchanges", effective["exchanges"])
+
+	return nil
+}
+
+// policyTargetSelected returns true if an object of the given kind and type
+// is one of the targets selected by apply_to.
+func policyTargetSelected(targets []string, kind string, objectType string) bool {
+	target := policyTargetExchanges
+	if kind == "queues" {
+		switch objectType {
+		case "quorum":
+			target = policyTargetQuorumQueues
+		case "stream":
+			target = policyTargetStreams
+		default:
+			// Brokers older than 3.8 don't report the type of classic queues
+			target = policyTargetClassicQueues
+		}
+	}
+
+	for _, t := range targets {
+		if t == target {
+			return true
+		}
+	}
+
+	return false
+}
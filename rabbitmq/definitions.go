@@ -0,0 +1,269 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+// Sections of a definitions document, with the fields identifying their
+// objects.
+// (reference: https://www.rabbitmq.com/definitions.html)
+var definitionSections = map[string][]string{
+	"users":             {"name"},
+	"vhosts":            {"name"},
+	"permissions":       {"user", "vhost"},
+	"topic_permissions": {"user", "vhost", "exchange"},
+	"parameters":        {"component", "vhost", "name"},
+	"global_parameters": {"name"},
+	"policies":          {"vhost", "name"},
+	"queues":            {"vhost", "name"},
+	"exchanges":         {"vhost", "name"},
+	"bindings":          {"vhost", "source", "destination", "destination_type", "routing_key", "arguments"},
+}
+
+// Fields of an export that describe the server rather than the definitions.
+var definitionsServerFields = []string{"rabbit_version", "rabbitmq_version", "product_name", "product_version"}
+
+// Global parameters generated by the server.
+var definitionsServerParameters = map[string]bool{
+	"internal_cluster_id": true,
+}
+
+func definitionsPath(vhost string) string {
+	if vhost == "" {
+		return "definitions"
+	}
+	return "definitions/" + url.PathEscape(vhost)
+}
+
+func getDefinitions(rmqc *rabbitmqClient, vhost string) (map[string]interface{}, error) {
+	resp, err := rmqc.executeRequest("GET", definitionsPath(vhost), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+
+	var definitions map[string]interface{}
+	if err := decoder.Decode(&definitions); err != nil {
+		return nil, fmt.Errorf("Unable to parse RabbitMQ definitions: %s", err)
+	}
+
+	return definitions, nil
+}
+
+// parseDefinitions decodes a definitions document. Numbers are kept as they
+// are written, so that no precision is lost.
+func parseDefinitions(s string) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+
+	var definitions map[string]interface{}
+	if err := decoder.Decode(&definitions); err != nil {
+		return nil, fmt.Errorf("Unable to parse definitions: %s", err)
+	}
+
+	return definitions, nil
+}
+
+// normaliseDefinitions returns a copy of the definitions that only differs
+// from the copy of equivalent definitions by the order of their keys: fields
+// generated by the server and empty sections are removed, the objects of
+// each section are sorted, and user tags are always a sorted list. Objects of
+// a document scoped to a vhost don't include their vhost, which RabbitMQ
+// ignores anyway.
+func normaliseDefinitions(definitions map[string]interface{}, vhost string) map[string]interface{} {
+	normalised := make(map[string]interface{}, len(definitions))
+	for key, value := range definitions {
+		normalised[key] = value
+	}
+
+	for _, key := range definitionsServerFields {
+		delete(normalised, key)
+	}
+
+	for section := range definitionSections {
+		objects, ok := normalised[section].([]interface{})
+		if !ok {
+			continue
+		}
+
+		kept := make([]interface{}, 0, len(objects))
+		for _, o := range objects {
+			object, ok := o.(map[string]interface{})
+			if !ok {
+				kept = append(kept, o)
+				continue
+			}
+
+			if section == "global_parameters" && definitionsServerParameters[fmt.Sprint(object["name"])] {
+				continue
+			}
+
+			object = normaliseDefinitionsObject(section, object, vhost)
+			kept = append(kept, object)
+		}
+
+		if len(kept) == 0 {
+			delete(normalised, section)
+			continue
+		}
+
+		sort.SliceStable(kept, func(i, j int) bool {
+			return definitionsObjectKey(section, kept[i]) < definitionsObjectKey(section, kept[j])
+		})
+		normalised[section] = kept
+	}
+
+	return normalised
+}
+
+func normaliseDefinitionsObject(section string, object map[string]interface{}, vhost string) map[string]interface{} {
+	normalised := make(map[string]interface{}, len(object))
+	for key, value := range object {
+		normalised[key] = value
+	}
+
+	if vhost != "" {
+		delete(normalised, "vhost")
+	}
+
+	// Tags are exported as a list since RabbitMQ 3.12, and as a comma
+	// separated string before.
+	if section == "users" {
+		if tags, ok := normalised["tags"].(string); ok {
+			list := []interface{}{}
+			for _, tag := range strings.Split(tags, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					list = append(list, tag)
+				}
+			}
+			normalised["tags"] = list
+		}
+		if tags, ok := normalised["tags"].([]interface{}); ok {
+			sorted := append([]interface{}{}, tags...)
+			sort.Slice(sorted, func(i, j int) bool { return fmt.Sprint(sorted[i]) < fmt.Sprint(sorted[j]) })
+			normalised["tags"] = sorted
+		}
+	}
+
+	return normalised
+}
+
+// definitionsObjectKey identifies an object of a section. Objects of unknown
+// sections, or that aren't JSON objects, are identified by their content.
+func definitionsObjectKey(section string, o interface{}) string {
+	object, ok := o.(map[string]interface{})
+	fields, known := definitionSections[section]
+	if !ok || !known {
+		bytes, _ := json.Marshal(o)
+		return string(bytes)
+	}
+
+	id := make([]interface{}, len(fields))
+	for i, field := range fields {
+		id[i] = object[field]
+	}
+
+	bytes, _ := json.Marshal(id)
+	return string(bytes)
+}
+
+// projectDefinitions returns the declared definitions, with the values of
+// the current definitions. Fields of the declared objects that aren't
+// exported, such as plain-text passwords, keep their declared value, and
+// declared objects missing from the current definitions are removed. Both
+// documents are expected to be normalised.
+func projectDefinitions(declared, current map[string]interface{}) map[string]interface{} {
+	projected := make(map[string]interface{}, len(declared))
+	for key, value := range declared {
+		projected[key] = value
+	}
+
+	for section := range definitionSections {
+		objects, ok := declared[section].([]interface{})
+		if !ok {
+			continue
+		}
+
+		existing := map[string]map[string]interface{}{}
+		if currentObjects, ok := current[section].([]interface{}); ok {
+			for _, o := range currentObjects {
+				if object, ok := o.(map[string]interface{}); ok {
+					existing[definitionsObjectKey(section, object)] = object
+				}
+			}
+		}
+
+		kept := make([]interface{}, 0, len(objects))
+		for _, o := range objects {
+			object, ok := o.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			currentObject, ok := existing[definitionsObjectKey(section, object)]
+			if !ok {
+				continue
+			}
+
+			p := make(map[string]interface{}, len(object))
+			for key, value := range object {
+				if v, ok := currentObject[key]; ok {
+					value = v
+				}
+				p[key] = value
+			}
+			kept = append(kept, p)
+		}
+
+		if len(kept) == 0 {
+			delete(projected, section)
+			continue
+		}
+		projected[section] = kept
+	}
+
+	return projected
+}
+
+// normaliseDefinitionsJson returns the normalised JSON encoding of a
+// definitions document.
+func normaliseDefinitionsJson(s string, vhost string) (string, error) {
+	definitions, err := parseDefinitions(s)
+	if err != nil {
+		return "", err
+	}
+
+	bytes, err := json.Marshal(normaliseDefinitions(definitions, vhost))
+	if err != nil {
+		return "", fmt.Errorf("could not encode definitions as JSON: %w", err)
+	}
+
+	return string(bytes), nil
+}
+
+// suppressDefinitionsDiff ignores the differences that normaliseDefinitions
+// removes.
+func suppressDefinitionsDiff(k, old, new string, d *schema.ResourceData) bool {
+	vhost := d.Get("vhost").(string)
+
+	o, err := normaliseDefinitionsJson(old, vhost)
+	if err != nil {
+		return false
+	}
+
+	n, err := normaliseDefinitionsJson(new, vhost)
+	if err != nil {
+		return false
+	}
+
+	return o == n
+}
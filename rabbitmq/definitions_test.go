@@ -0,0 +1,93 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNormaliseDefinitionsJson(t *testing.T) {
+	var inputs = []struct {
+		vhost string
+		a, b  string
+	}{
+		// Key and object ordering, server fields and empty sections
+		{
+			"",
+			`{"rabbit_version": "3.8.9", "queues": [{"name": "b", "vhost": "/"}, {"vhost": "/", "name": "a"}], "exchanges": []}`,
+			`{"queues": [{"name": "a", "vhost": "/"}, {"name": "b", "vhost": "/"}]}`,
+		},
+		// Generated global parameters and user tags
+		{
+			"",
+			`{"global_parameters": [{"name": "internal_cluster_id", "value": "rabbitmq-cluster-id-x"}], "users": [{"name": "app", "tags": "monitoring,management"}]}`,
+			`{"users": [{"name": "app", "tags": ["management", "monitoring"]}]}`,
+		},
+		// Vhosts of documents scoped to a vhost
+		{
+			"test",
+			`{"queues": [{"name": "a", "vhost": "test", "durable": true}]}`,
+			`{"queues": [{"name": "a", "durable": true}]}`,
+		},
+	}
+
+	for _, test := range inputs {
+		a, err := normaliseDefinitionsJson(test.a, test.vhost)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		b, err := normaliseDefinitionsJson(test.b, test.vhost)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if a != b {
+			t.Errorf("normaliseDefinitionsJson differs for: %s and %s. Got %s and %s", test.a, test.b, a, b)
+		}
+	}
+
+	a, _ := normaliseDefinitionsJson(`{"queues": [{"name": "a", "durable": true}]}`, "")
+	b, _ := normaliseDefinitionsJson(`{"queues": [{"name": "a", "durable": false}]}`, "")
+	if a == b {
+		t.Errorf("normaliseDefinitionsJson should differ for different values. Got %s", a)
+	}
+}
+
+func TestProjectDefinitions(t *testing.T) {
+	declared, err := parseDefinitions(`{
+		"users": [{"name": "app", "password": "secret", "tags": ""}],
+		"queues": [
+			{"name": "a", "vhost": "/", "durable": true},
+			{"name": "deleted", "vhost": "/", "durable": true}
+		]
+	}`)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	current, err := parseDefinitions(`{
+		"rabbit_version": "3.8.9",
+		"users": [{"name": "app", "password_hash": "xyz", "tags": "management"}],
+		"queues": [
+			{"name": "a", "vhost": "/", "durable": false, "auto_delete": false, "arguments": {}},
+			{"name": "unmanaged", "vhost": "/", "durable": true}
+		]
+	}`)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	projected := projectDefinitions(normaliseDefinitions(declared, ""), normaliseDefinitions(current, ""))
+
+	expected, err := parseDefinitions(`{
+		"users": [{"name": "app", "password": "secret", "tags": ["management"]}],
+		"queues": [{"name": "a", "vhost": "/", "durable": false}]
+	}`)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	p, _ := json.Marshal(projected)
+	e, _ := json.Marshal(expected)
+	if string(p) != string(e) {
+		t.Errorf("projectDefinitions failed. Got %s, expected %s", p, e)
+	}
+}
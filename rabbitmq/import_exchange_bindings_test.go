@@ -0,0 +1,31 @@
+package rabbitmq
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+)
+
+func TestAccExchangeBindings_importBasic(t *testing.T) {
+	resourceName := "rabbitmq_exchange_bindings.test"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccExchangeBindingsCheckDestroy("test", "test"),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccExchangeBindingsConfig_basic,
+				Check: testAccExchangeBindingsCheck(
+					resourceName, "orders.#", "invoices.#",
+				),
+			},
+
+			{
+				ResourceName:      resourceName,
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
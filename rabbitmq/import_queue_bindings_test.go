@@ -0,0 +1,31 @@
+package rabbitmq
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+)
+
+func TestAccQueueBindings_importBasic(t *testing.T) {
+	resourceName := "rabbitmq_queue_bindings.test"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccQueueBindingsCheckDestroy("a", "test"),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccQueueBindingsConfig_basic,
+				Check: testAccQueueBindingsCheck(
+					resourceName, "orders.#",
+				),
+			},
+
+			{
+				ResourceName:      resourceName,
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
@@ -0,0 +1,32 @@
+package rabbitmq
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+)
+
+func TestAccServiceAccount_importBasic(t *testing.T) {
+	resourceName := "rabbitmq_service_account.test"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccServiceAccountCheckDestroy("mcservice"),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccServiceAccountConfig_basic,
+				Check: testAccServiceAccountCheck(
+					resourceName, []string{"test"},
+				),
+			},
+
+			{
+				ResourceName:            resourceName,
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"password"},
+			},
+		},
+	})
+}
@@ -17,7 +17,7 @@ func TestAccTopicPermissions_importBasic(t *testing.T) {
 	var expectErr1 *regexp.Regexp
 	checkDestroy := testAccTopicPermissionsCheckDestroy(&topicPermissionInfo)
 	if os.Getenv("RABBITMQ_VERSION") == "3.6" {
-		expectErr0, _ = regexp.Compile("^errors during apply: Topic permissions were adding in RabbitMQ 3.7, connected to 3.6.*$")
+		expectErr0, _ = regexp.Compile(`rabbitmq_topic_permissions requires RabbitMQ >= 3\.7\.0, connected to 3\.6`)
 		expectErr1, _ = regexp.Compile("^Resource specified by ResourceName couldn't be found: rabbitmq_topic_permissions.test$")
 		checkDestroy = nil
 	}
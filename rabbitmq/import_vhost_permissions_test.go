@@ -0,0 +1,31 @@
+package rabbitmq
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+)
+
+func TestAccVhostPermissions_importBasic(t *testing.T) {
+	resourceName := "rabbitmq_vhost_permissions.test"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccVhostPermissionsCheckDestroy("test"),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccVhostPermissionsConfig_basic,
+				Check: testAccVhostPermissionsCheck(
+					resourceName, []string{"mctest"},
+				),
+			},
+
+			{
+				ResourceName:      resourceName,
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
@@ -0,0 +1,309 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+)
+
+// Kinds of objects a policy can apply to.
+const (
+	policyTargetExchanges     = "exchanges"
+	policyTargetClassicQueues = "classic_queues"
+	policyTargetQuorumQueues  = "quorum_queues"
+	policyTargetStreams       = "streams"
+)
+
+// Values of apply_to, with the kinds of objects they select. The queue type
+// specific values were added in RabbitMQ 3.12.
+var policyApplyTo = map[string][]string{
+	"all":            {policyTargetExchanges, policyTargetClassicQueues, policyTargetQuorumQueues, policyTargetStreams},
+	"exchanges":      {policyTargetExchanges},
+	"queues":         {policyTargetClassicQueues, policyTargetQuorumQueues, policyTargetStreams},
+	"classic_queues": {policyTargetClassicQueues},
+	"quorum_queues":  {policyTargetQuorumQueues},
+	"streams":        {policyTargetStreams},
+}
+
+var policyApplyToMinVersion = map[string][3]int{
+	"classic_queues": {3, 12, 0},
+	"quorum_queues":  {3, 12, 0},
+	"streams":        {3, 12, 0},
+}
+
+type policyValueType int
+
+const (
+	policyValueString policyValueType = iota
+	policyValueInt
+	policyValueBool
+	// ha-params is a number of replicas or a list of nodes
+	policyValueIntOrList
+)
+
+func (t policyValueType) String() string {
+	switch t {
+	case policyValueInt:
+		return "an integer"
+	case policyValueBool:
+		return "a boolean"
+	case policyValueIntOrList:
+		return "an integer or a list"
+	}
+	return "a string"
+}
+
+type policyKey struct {
+	valueType policyValueType
+	values    []string
+	targets   []string
+	// Minimum version of RabbitMQ, zero when the key is supported by every
+	// version the provider works with.
+	major, minor, patch int
+}
+
+var allQueues = []string{policyTargetClassicQueues, policyTargetQuorumQueues, policyTargetStreams}
+
+// Policy keys known by RabbitMQ and its bundled plugins.
+// (reference: https://www.rabbitmq.com/parameters.html#policies)
+var policyKeys = map[string]policyKey{
+	"alternate-exchange":      {valueType: policyValueString, targets: []string{policyTargetExchanges}},
+	"federation-upstream":     {valueType: policyValueString, targets: []string{policyTargetExchanges, policyTargetClassicQueues, policyTargetQuorumQueues}},
+	"federation-upstream-set": {valueType: policyValueString, targets: []string{policyTargetExchanges, policyTargetClassicQueues, policyTargetQuorumQueues}},
+
+	"message-ttl":             {valueType: policyValueInt, targets: []string{policyTargetClassicQueues, policyTargetQuorumQueues}},
+	"expires":                 {valueType: policyValueInt, targets: allQueues},
+	"max-length":              {valueType: policyValueInt, targets: []string{policyTargetClassicQueues, policyTargetQuorumQueues}},
+	"max-length-bytes":        {valueType: policyValueInt, targets: allQueues},
+	"overflow":                {valueType: policyValueString, values: []string{"drop-head", "reject-publish", "reject-publish-dlx"}, targets: []string{policyTargetClassicQueues, policyTargetQuorumQueues}, major: 3, minor: 7},
+	"dead-letter-exchange":    {valueType: policyValueString, targets: []string{policyTargetClassicQueues, policyTargetQuorumQueues}},
+	"dead-letter-routing-key": {valueType: policyValueString, targets: []string{policyTargetClassicQueues, policyTargetQuorumQueues}},
+	"queue-master-locator":    {valueType: policyValueString, values: []string{"min-masters", "client-local", "random"}, targets: allQueues, major: 3, minor: 6},
+	"queue-leader-locator":    {valueType: policyValueString, values: []string{"client-local", "balanced"}, targets: []string{policyTargetQuorumQueues, policyTargetStreams}, major: 3, minor: 10},
+
+	"ha-mode":                {valueType: policyValueString, values: []string{"all", "exactly", "nodes"}, targets: []string{policyTargetClassicQueues}},
+	"ha-params":              {valueType: policyValueIntOrList, targets: []string{policyTargetClassicQueues}},
+	"ha-sync-mode":           {valueType: policyValueString, values: []string{"manual", "automatic"}, targets: []string{policyTargetClassicQueues}},
+	"ha-sync-batch-size":     {valueType: policyValueInt, targets: []string{policyTargetClassicQueues}, major: 3, minor: 6},
+	"ha-promote-on-shutdown": {valueType: policyValueString, values: []string{"when-synced", "always"}, targets: []string{policyTargetClassicQueues}},
+	"ha-promote-on-failure":  {valueType: policyValueString, values: []string{"when-synced", "always"}, targets: []string{policyTargetClassicQueues}, major: 3, minor: 7},
+	"queue-mode":             {valueType: policyValueString, values: []string{"default", "lazy"}, targets: []string{policyTargetClassicQueues}, major: 3, minor: 6},
+	"queue-version":          {valueType: policyValueInt, targets: []string{policyTargetClassicQueues}, major: 3, minor: 10},
+
+	"delivery-limit":       {valueType: policyValueInt, targets: []string{policyTargetQuorumQueues}, major: 3, minor: 8},
+	"max-in-memory-length": {valueType: policyValueInt, targets: []string{policyTargetQuorumQueues}, major: 3, minor: 8},
+	"max-in-memory-bytes":  {valueType: policyValueInt, targets: []string{policyTargetQuorumQueues}, major: 3, minor: 8},
+	"dead-letter-strategy": {valueType: policyValueString, values: []string{"at-most-once", "at-least-once"}, targets: []string{policyTargetQuorumQueues}, major: 3, minor: 10},
+
+	"max-age":                       {valueType: policyValueString, targets: []string{policyTargetStreams}, major: 3, minor: 9},
+	"stream-max-segment-size-bytes": {valueType: policyValueInt, targets: []string{policyTargetStreams}, major: 3, minor: 9},
+}
+
+func policyApplyToValues() []string {
+	values := make([]string, 0, len(policyApplyTo))
+	for value := range policyApplyTo {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// validatePolicyDefinitionKeys is the ValidateFunc of the definition map. It
+// warns about the keys missing from the catalogue of known keys, which are
+// only warnings since plugins may define their own keys.
+func validatePolicyDefinitionKeys(v interface{}, k string) ([]string, []error) {
+	definition, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	return unknownPolicyKeyWarnings(k, definition), nil
+}
+
+// validatePolicyDefinitionJson is the ValidateFunc of definition_json, which
+// warns about unknown keys like validatePolicyDefinitionKeys.
+func validatePolicyDefinitionJson(v interface{}, k string) ([]string, []error) {
+	if ws, es := validation.ValidateJsonString(v, k); len(es) > 0 {
+		return ws, es
+	}
+
+	definition, err := PolicyDefinition(map[string]interface{}{"definition_json": v})
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	return unknownPolicyKeyWarnings(k, definition), nil
+}
+
+func unknownPolicyKeyWarnings(k string, definition map[string]interface{}) []string {
+	keys := make([]string, 0, len(definition))
+	for key := range definition {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var warnings []string
+	for _, key := range keys {
+		if _, ok := policyKeys[key]; ok {
+			continue
+		}
+
+		if known := closestPolicyKey(key); known != "" {
+			warnings = append(warnings, fmt.Sprintf("%s: unknown policy key %q, did you mean %q?", k, key, known))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("%s: unknown policy key %q, it will be ignored unless a plugin supports it", k, key))
+		}
+	}
+
+	return warnings
+}
+
+// validatePolicyDefinition checks the known keys of a policy definition
+// against the target of the policy and the version of the broker. Unknown
+// keys are left to validatePolicyDefinitionKeys.
+func validatePolicyDefinition(rmqc *rabbitmqClient, applyTo string, definition map[string]interface{}) error {
+	if v, ok := policyApplyToMinVersion[applyTo]; ok {
+		if err := rmqc.requireVersion(fmt.Sprintf("apply_to = %q", applyTo), v[0], v[1], v[2]); err != nil {
+			return err
+		}
+	}
+
+	keys := make([]string, 0, len(definition))
+	for key := range definition {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		spec, ok := policyKeys[key]
+		if !ok {
+			continue
+		}
+
+		if !policyKeyApplies(spec, applyTo) {
+			return fmt.Errorf("Policy key %q doesn't apply to %s, only to %s", key, applyTo, strings.Join(spec.targets, ", "))
+		}
+
+		if spec.major > 0 {
+			if err := rmqc.requireVersion(fmt.Sprintf("Policy key %q", key), spec.major, spec.minor, spec.patch); err != nil {
+				return err
+			}
+		}
+
+		if err := validatePolicyValue(key, spec, definition[key]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func policyKeyApplies(spec policyKey, applyTo string) bool {
+	selected, ok := policyApplyTo[applyTo]
+	if !ok {
+		// apply_to itself is validated by the schema
+		return true
+	}
+
+	for _, target := range selected {
+		for _, t := range spec.targets {
+			if t == target {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// validatePolicyValue checks a value of the definition. Values of the
+// definition map are strings, converted the way putPolicy does, while values
+// of definition_json keep their JSON type.
+func validatePolicyValue(key string, spec policyKey, value interface{}) error {
+	valid := false
+	switch v := value.(type) {
+	case string:
+		switch spec.valueType {
+		case policyValueInt, policyValueIntOrList:
+			_, err := strconv.ParseInt(v, 10, 64)
+			valid = err == nil || (spec.valueType == policyValueIntOrList && v != "")
+		case policyValueBool:
+			_, err := strconv.ParseBool(v)
+			valid = err == nil
+		default:
+			valid = true
+		}
+	case json.Number:
+		_, err := v.Int64()
+		valid = err == nil && (spec.valueType == policyValueInt || spec.valueType == policyValueIntOrList)
+	case bool:
+		valid = spec.valueType == policyValueBool
+	case []interface{}:
+		valid = spec.valueType == policyValueIntOrList
+	}
+
+	if !valid {
+		return fmt.Errorf("Policy key %q must be %s, got %v", key, spec.valueType, value)
+	}
+
+	if len(spec.values) > 0 {
+		for _, allowed := range spec.values {
+			if value == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("Policy key %q must be one of %s, got %v", key, strings.Join(spec.values, ", "), value)
+	}
+
+	return nil
+}
+
+// closestPolicyKey returns the known key closest to key, if it is close
+// enough to be a typo.
+func closestPolicyKey(key string) string {
+	closest := ""
+	best := 3
+	for known := range policyKeys {
+		if d := editDistance(key, known); d < best || (d == best && known < closest) {
+			closest, best = known, d
+		}
+	}
+	return closest
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
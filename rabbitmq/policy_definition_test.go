@@ -0,0 +1,120 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidatePolicyDefinition(t *testing.T) {
+	rmqc := &rabbitmqClient{version: serverVersion{major: 3, minor: 8, raw: "3.8.0"}}
+
+	var goodInputs = []struct {
+		applyTo    string
+		definition map[string]interface{}
+	}{
+		{"all", map[string]interface{}{"ha-mode": "nodes", "ha-params": "a,b,c", "max-length": "10000"}},
+		{"queues", map[string]interface{}{"ha-mode": "exactly", "ha-params": json.Number("2")}},
+		{"queues", map[string]interface{}{"ha-mode": "nodes", "ha-params": []interface{}{"a", "b"}}},
+		{"exchanges", map[string]interface{}{"alternate-exchange": "unrouted", "federation-upstream-set": "all"}},
+		{"queues", map[string]interface{}{"delivery-limit": json.Number("5"), "overflow": "reject-publish"}},
+		{"queues", map[string]interface{}{"x-plugin-setting": "value"}},
+	}
+
+	for _, test := range goodInputs {
+		if err := validatePolicyDefinition(rmqc, test.applyTo, test.definition); err != nil {
+			t.Errorf("validatePolicyDefinition failed for: %v: %s", test.definition, err)
+		}
+	}
+
+	var badInputs = []struct {
+		applyTo    string
+		definition map[string]interface{}
+		message    string
+	}{
+		{"exchanges", map[string]interface{}{"message-ttl": "1000"}, "doesn't apply to exchanges"},
+		{"queues", map[string]interface{}{"message-ttl": "1s"}, "must be an integer"},
+		{"queues", map[string]interface{}{"message-ttl": "1000.5"}, "must be an integer"},
+		{"queues", map[string]interface{}{"message-ttl": json.Number("1000.5")}, "must be an integer"},
+		{"queues", map[string]interface{}{"ha-mode": "some"}, "must be one of all, exactly, nodes"},
+		{"queues", map[string]interface{}{"dead-letter-strategy": "at-least-once"}, "requires RabbitMQ >= 3.10.0"},
+		{"quorum_queues", map[string]interface{}{"delivery-limit": "5"}, "requires RabbitMQ >= 3.12.0"},
+	}
+
+	for _, test := range badInputs {
+		err := validatePolicyDefinition(rmqc, test.applyTo, test.definition)
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("validatePolicyDefinition should fail for: %v with %q, got %v", test.definition, test.message, err)
+		}
+	}
+}
+
+func TestValidatePolicyDefinitionKeys(t *testing.T) {
+	var inputs = []struct {
+		definition interface{}
+		warning    string
+	}{
+		{map[string]interface{}{"message-ttl": "1000"}, ""},
+		{map[string]interface{}{"messsage-ttl": "1000"}, `did you mean "message-ttl"`},
+		{map[string]interface{}{"x-plugin-setting": "value"}, "unless a plugin supports it"},
+	}
+
+	for _, test := range inputs {
+		ws, es := validatePolicyDefinitionKeys(test.definition, "definition")
+		if len(es) > 0 {
+			t.Errorf("validatePolicyDefinitionKeys failed for: %v: %v", test.definition, es)
+		}
+		if test.warning == "" && len(ws) > 0 || test.warning != "" && (len(ws) != 1 || !strings.Contains(ws[0], test.warning)) {
+			t.Errorf("validatePolicyDefinitionKeys should warn for: %v with %q, got %v", test.definition, test.warning, ws)
+		}
+	}
+
+	ws, es := validatePolicyDefinitionJson(`{"max-lenght": 10}`, "definition_json")
+	if len(es) > 0 || len(ws) != 1 || !strings.Contains(ws[0], `did you mean "max-length"`) {
+		t.Errorf("validatePolicyDefinitionJson should warn about max-lenght, got %v, %v", ws, es)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	var inputs = []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"message-ttl", "message-ttl", 0},
+		{"messsage-ttl", "message-ttl", 1},
+		{"max-lenght", "max-length", 2},
+		{"expires", "", 7},
+	}
+
+	for _, test := range inputs {
+		if d := editDistance(test.a, test.b); d != test.expected {
+			t.Errorf("editDistance failed for: %s, %s. Got %d", test.a, test.b, d)
+		}
+	}
+}
+
+func TestPolicyTargetSelected(t *testing.T) {
+	var inputs = []struct {
+		applyTo    string
+		kind       string
+		objectType string
+		expected   bool
+	}{
+		{"all", "exchanges", "topic", true},
+		{"all", "queues", "", true},
+		{"exchanges", "queues", "classic", false},
+		{"queues", "exchanges", "direct", false},
+		{"queues", "queues", "stream", true},
+		{"classic_queues", "queues", "", true},
+		{"classic_queues", "queues", "quorum", false},
+		{"quorum_queues", "queues", "quorum", true},
+		{"streams", "queues", "classic", false},
+	}
+
+	for _, test := range inputs {
+		if selected := policyTargetSelected(policyApplyTo[test.applyTo], test.kind, test.objectType); selected != test.expected {
+			t.Errorf("policyTargetSelected failed for: %s %s of type %q. Got %v", test.applyTo, test.kind, test.objectType, selected)
+		}
+	}
+}
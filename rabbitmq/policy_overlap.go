@@ -0,0 +1,111 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+)
+
+// Values of overlap_check
+const (
+	policyOverlapWarn  = "warn"
+	policyOverlapError = "error"
+)
+
+// checkPolicyOverlaps looks for other policies of the vhost that have the same
+// priority as policy and apply to a queue or an exchange it applies to.
+// RabbitMQ only applies one of them, and which one is not deterministic.
+func checkPolicyOverlaps(rmqc *rabbitmqClient, policy rabbithole.Policy, mode string) error {
+	others, err := rmqc.ListPoliciesIn(policy.Vhost)
+	if err != nil {
+		if rmqErr, ok := err.(rabbithole.ErrorResponse); ok && rmqErr.StatusCode == 404 {
+			// the vhost doesn't exist yet
+			return nil
+		}
+		return err
+	}
+
+	candidates := make([]rabbithole.Policy, 0, len(others))
+	for _, other := range others {
+		if other.Name != policy.Name && other.Priority == policy.Priority {
+			candidates = append(candidates, other)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	objects := map[string][]policyTargetInfo{}
+	for _, kind := range []string{"queues", "exchanges"} {
+		if objects[kind], err = listPolicyTargets(rmqc, kind, policy.Vhost); err != nil {
+			return err
+		}
+	}
+
+	overlaps := policyOverlaps(policy, candidates, objects)
+	if len(overlaps) == 0 {
+		return nil
+	}
+
+	if mode == policyOverlapError {
+		return fmt.Errorf("Policy %s overlaps with other policies of the same priority:\n\n%s", policy.Name, strings.Join(overlaps, "\n"))
+	}
+
+	for _, overlap := range overlaps {
+		log.Printf("[WARN] RabbitMQ: Policy %s overlaps with %s", policy.Name, overlap)
+	}
+
+	return nil
+}
+
+// policyOverlaps returns a description of each policy of others that applies
+// to an object the policy applies to, objects being the queues and exchanges
+// of the vhost by kind. Patterns that don't compile are skipped, since Go
+// regular expressions don't support every construct RabbitMQ does.
+func policyOverlaps(policy rabbithole.Policy, others []rabbithole.Policy, objects map[string][]policyTargetInfo) []string {
+	pattern, err := regexp.Compile(policy.Pattern)
+	if err != nil {
+		log.Printf("[DEBUG] RabbitMQ: Skipping overlap check of policy %s: %s", policy.Name, err)
+		return nil
+	}
+
+	var overlaps []string
+	for _, other := range others {
+		otherPattern, err := regexp.Compile(other.Pattern)
+		if err != nil {
+			log.Printf("[DEBUG] RabbitMQ: Skipping overlap check with policy %s: %s", other.Name, err)
+			continue
+		}
+
+	kinds:
+		for _, kind := range []string{"queues", "exchanges"} {
+			for _, object := range objects[kind] {
+				// Policies never apply to the default exchange.
+				if kind == "exchanges" && object.Name == "" {
+					continue
+				}
+
+				if !pattern.MatchString(object.Name) || !otherPattern.MatchString(object.Name) {
+					continue
+				}
+
+				if !policyTargetSelected(policyApplyTo[policy.ApplyTo], kind, object.Type) ||
+					!policyTargetSelected(policyApplyTo[other.ApplyTo], kind, object.Type) {
+					continue
+				}
+
+				// One example is enough to show the policies overlap.
+				overlaps = append(overlaps, fmt.Sprintf("policy %s (priority %d) on %s %q", other.Name, other.Priority, strings.TrimSuffix(kind, "s"), object.Name))
+				break kinds
+			}
+		}
+	}
+
+	sort.Strings(overlaps)
+	return overlaps
+}
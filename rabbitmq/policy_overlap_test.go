@@ -0,0 +1,43 @@
+package rabbitmq
+
+import (
+	"reflect"
+	"testing"
+
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+)
+
+func TestPolicyOverlaps(t *testing.T) {
+	objects := map[string][]policyTargetInfo{
+		"queues": {
+			{Name: "orders.created"},
+			{Name: "orders.stream", Type: "stream"},
+			{Name: "invoices"},
+		},
+		"exchanges": {
+			{Name: ""},
+			{Name: "orders.events", Type: "topic"},
+		},
+	}
+
+	policy := rabbithole.Policy{Name: "orders", Pattern: "^orders\\.", ApplyTo: "queues"}
+
+	var inputs = []struct {
+		other    rabbithole.Policy
+		expected []string
+	}{
+		{rabbithole.Policy{Name: "all", Pattern: ".*", ApplyTo: "all"}, []string{`policy all (priority 0) on queue "orders.created"`}},
+		{rabbithole.Policy{Name: "streams", Pattern: "stream$", ApplyTo: "streams"}, []string{`policy streams (priority 0) on queue "orders.stream"`}},
+		{rabbithole.Policy{Name: "invoices", Pattern: "^invoices", ApplyTo: "queues"}, nil},
+		{rabbithole.Policy{Name: "exchanges", Pattern: ".*", ApplyTo: "exchanges"}, nil},
+		{rabbithole.Policy{Name: "quorum", Pattern: ".*", ApplyTo: "quorum_queues"}, nil},
+		{rabbithole.Policy{Name: "invalid", Pattern: "(?<=orders)", ApplyTo: "all"}, nil},
+	}
+
+	for _, test := range inputs {
+		overlaps := policyOverlaps(policy, []rabbithole.Policy{test.other}, objects)
+		if !reflect.DeepEqual(overlaps, test.expected) {
+			t.Errorf("policyOverlaps failed for: %s. Got %v", test.other.Name, overlaps)
+		}
+	}
+}
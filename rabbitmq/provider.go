@@ -5,11 +5,13 @@ import (
 	"crypto/x509"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
 
 	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
 	"github.com/hashicorp/terraform-plugin-sdk/terraform"
 )
 
@@ -17,9 +19,10 @@ func Provider() terraform.ResourceProvider {
 	return &schema.Provider{
 		Schema: map[string]*schema.Schema{
 			"endpoint": {
-				Type:        schema.TypeString,
-				Required:    true,
-				DefaultFunc: schema.EnvDefaultFunc("RABBITMQ_ENDPOINT", nil),
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("RABBITMQ_ENDPOINT", nil),
+				ConflictsWith: []string{"endpoints"},
 				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
 					value := v.(string)
 					if value == "" {
@@ -30,6 +33,24 @@ func Provider() terraform.ResourceProvider {
 				},
 			},
 
+			"endpoints": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				MinItems:      1,
+				ConflictsWith: []string{"endpoint"},
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+					ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+						value := v.(string)
+						if value == "" {
+							errors = append(errors, fmt.Errorf("Endpoints must not contain an empty string"))
+						}
+
+						return
+					},
+				},
+			},
+
 			"username": {
 				Type:        schema.TypeString,
 				Required:    true,
@@ -58,6 +79,20 @@ func Provider() terraform.ResourceProvider {
 				},
 			},
 
+			"max_concurrent_requests": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("RABBITMQ_MAX_CONCURRENT_REQUESTS", 0),
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+
+			"max_requests_per_second": {
+				Type:         schema.TypeFloat,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("RABBITMQ_MAX_REQUESTS_PER_SECOND", 0.0),
+				ValidateFunc: validation.FloatBetween(0, math.MaxFloat64),
+			},
+
 			"insecure": {
 				Type:        schema.TypeBool,
 				Optional:    true,
@@ -83,16 +118,28 @@ func Provider() terraform.ResourceProvider {
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
-			"rabbitmq_binding":             resourceBinding(),
-			"rabbitmq_exchange":            resourceExchange(),
-			"rabbitmq_permissions":         resourcePermissions(),
-			"rabbitmq_topic_permissions":   resourceTopicPermissions(),
-			"rabbitmq_federation_upstream": resourceFederationUpstream(),
-			"rabbitmq_policy":              resourcePolicy(),
-			"rabbitmq_queue":               resourceQueue(),
-			"rabbitmq_user":                resourceUser(),
-			"rabbitmq_vhost":               resourceVhost(),
-			"rabbitmq_shovel":              resourceShovel(),
+			"rabbitmq_binding":                resourceBinding(),
+			"rabbitmq_definitions":            resourceDefinitions(),
+			"rabbitmq_exchange":               resourceExchange(),
+			"rabbitmq_exchange_bindings":      resourceExchangeBindings(),
+			"rabbitmq_permissions":            resourcePermissions(),
+			"rabbitmq_topic_permissions":      resourceTopicPermissions(),
+			"rabbitmq_federation_upstream":    resourceFederationUpstream(),
+			"rabbitmq_policy":                 resourcePolicy(),
+			"rabbitmq_queue":                  resourceQueue(),
+			"rabbitmq_queue_bindings":         resourceQueueBindings(),
+			"rabbitmq_user":                   resourceUser(),
+			"rabbitmq_user_password_rotation": resourceUserPasswordRotation(),
+			"rabbitmq_vhost":                  resourceVhost(),
+			"rabbitmq_vhost_permissions":      resourceVhostPermissions(),
+			"rabbitmq_service_account":        resourceServiceAccount(),
+			"rabbitmq_shovel":                 resourceShovel(),
+		},
+
+		DataSourcesMap: map[string]*schema.Resource{
+			"rabbitmq_definitions":    dataSourceDefinitions(),
+			"rabbitmq_password_hash":  dataSourcePasswordHash(),
+			"rabbitmq_policy_matches": dataSourcePolicyMatches(),
 		},
 
 		ConfigureFunc: providerConfigure,
@@ -103,11 +150,27 @@ func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 
 	var username = d.Get("username").(string)
 	var password = d.Get("password").(string)
-	var endpoint = d.Get("endpoint").(string)
 	var insecure = d.Get("insecure").(bool)
 	var cacertFile = d.Get("cacert_file").(string)
 	var clientcertFile = d.Get("clientcert_file").(string)
 	var clientkeyFile = d.Get("clientkey_file").(string)
+	var maxConcurrentRequests = d.Get("max_concurrent_requests").(int)
+	var maxRequestsPerSecond = d.Get("max_requests_per_second").(float64)
+
+	endpoints := []string{}
+	for _, v := range d.Get("endpoints").([]interface{}) {
+		if endpoint, ok := v.(string); ok {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	if len(endpoints) == 0 {
+		if endpoint, ok := d.Get("endpoint").(string); ok && endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("One of endpoint or endpoints must be set")
+	}
 
 	// Configure TLS/SSL:
 	// Ignore self-signed cert warnings
@@ -136,11 +199,34 @@ func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 	}
 
 	// Connect to RabbitMQ management interface
-	transport := &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment}
+	var transport http.RoundTripper = &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment}
+	transport = &loggingTransport{transport: transport}
+
+	// The limits apply to all resources, since they share this client.
+	if maxConcurrentRequests > 0 || maxRequestsPerSecond > 0 {
+		transport = newLimitingTransport(maxConcurrentRequests, maxRequestsPerSecond, transport)
+	}
+
+	// With several endpoints, stick to the first healthy node and fail over
+	// to the others when it becomes unavailable.
+	endpoint := endpoints[0]
+	if len(endpoints) > 1 {
+		failover, err := newFailoverTransport(endpoints, transport)
+		if err != nil {
+			return nil, err
+		}
+
+		endpoint, err = failover.selectHealthy(username, password)
+		if err != nil {
+			return nil, err
+		}
+		transport = failover
+	}
+
 	rmqc, err := rabbithole.NewTLSClient(endpoint, username, password, transport)
 	if err != nil {
 		return nil, err
 	}
 
-	return rmqc, nil
+	return newRabbitmqClient(rmqc, transport)
 }
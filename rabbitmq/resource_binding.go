@@ -15,11 +15,22 @@ import (
 
 func resourceBinding() *schema.Resource {
 	return &schema.Resource{
-		Create: CreateBinding,
-		Read:   ReadBinding,
-		Delete: DeleteBinding,
+		Create:        CreateBinding,
+		Update:        UpdateBinding,
+		Read:          ReadBinding,
+		Delete:        DeleteBinding,
+		CustomizeDiff: CustomizeDiffBinding,
 		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+			State: importBinding,
+		},
+
+		SchemaVersion: 1,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Type:    resourceBindingV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: resourceBindingStateUpgradeV0,
+				Version: 0,
+			},
 		},
 
 		Schema: map[string]*schema.Schema{
@@ -42,9 +53,10 @@ func resourceBinding() *schema.Resource {
 			},
 
 			"destination_type": {
-				Type:     schema.TypeString,
-				Required: true,
-				ForceNew: true,
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice([]string{"queue", "exchange"}, false),
 			},
 
 			"properties_key": {
@@ -55,19 +67,16 @@ func resourceBinding() *schema.Resource {
 			"routing_key": {
 				Type:     schema.TypeString,
 				Optional: true,
-				ForceNew: true,
 			},
 
 			"arguments": {
 				Type:          schema.TypeMap,
 				Optional:      true,
-				ForceNew:      true,
 				ConflictsWith: []string{"arguments_json"},
 			},
 			"arguments_json": {
 				Type:             schema.TypeString,
 				Optional:         true,
-				ForceNew:         true,
 				ValidateFunc:     validation.ValidateJsonString,
 				ConflictsWith:    []string{"arguments"},
 				DiffSuppressFunc: structure.SuppressJsonDiff,
@@ -77,29 +86,13 @@ func resourceBinding() *schema.Resource {
 }
 
 func CreateBinding(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
 	vhost := d.Get("vhost").(string)
-	arguments := d.Get("arguments").(map[string]interface{})
 
-	// If arguments_json is used, unmarshal it into a generic interface
-	// and use it as the "arguments" key for the binding.
-	if v, ok := d.Get("arguments_json").(string); ok && v != "" {
-		var arguments_json map[string]interface{}
-		err := json.Unmarshal([]byte(v), &arguments_json)
-		if err != nil {
-			return err
-		}
-
-		arguments = arguments_json
-	}
-
-	bindingInfo := rabbithole.BindingInfo{
-		Source:          d.Get("source").(string),
-		Destination:     d.Get("destination").(string),
-		DestinationType: d.Get("destination_type").(string),
-		RoutingKey:      d.Get("routing_key").(string),
-		Arguments:       arguments,
+	bindingInfo, err := bindingFromResourceData(d)
+	if err != nil {
+		return err
 	}
 
 	propertiesKey, err := declareBinding(rmqc, vhost, bindingInfo)
@@ -109,33 +102,31 @@ func CreateBinding(d *schema.ResourceData, meta interface{}) error {
 
 	log.Printf("[DEBUG] RabbitMQ: Binding properties key: %s", propertiesKey)
 	bindingInfo.PropertiesKey = propertiesKey
-	name := fmt.Sprintf("%s/%s/%s/%s/%s", percentEncodeSlashes(vhost), bindingInfo.Source, bindingInfo.Destination, bindingInfo.DestinationType, bindingInfo.PropertiesKey)
-	d.SetId(name)
+	bindingInfo.Vhost = vhost
+	d.SetId(BindingId(bindingInfo))
 
 	return ReadBinding(d, meta)
 }
 
 func ReadBinding(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
-	log.Printf("[TRACE] RabbitMQ: read binding resource ID (pre-split): %s", d.Id())
-	bindingId := strings.Split(d.Id(), "/")
-	log.Printf("[DEBUG] RabbitMQ: binding ID: %#v", bindingId)
-	if len(bindingId) < 5 {
-		return fmt.Errorf("Unable to determine binding ID")
+	bindingId, err := parseBindingId(d.Id())
+	if err != nil {
+		return err
 	}
 
-	vhost := percentDecodeSlashes(bindingId[0])
-	source := bindingId[1]
-	destination := bindingId[2]
-	destinationType := bindingId[3]
-	propertiesKey := bindingId[4]
+	vhost := bindingId.Vhost
+	source := bindingId.Source
+	destination := bindingId.Destination
+	destinationType := bindingId.DestinationType
+	propertiesKey := bindingId.PropertiesKey
 	log.Printf("[DEBUG] RabbitMQ: Attempting to find binding for: vhost=%s source=%s destination=%s destinationType=%s propertiesKey=%s",
 		vhost, source, destination, destinationType, propertiesKey)
 
-	bindings, err := rmqc.ListBindingsIn(vhost)
+	bindings, err := listBindingsBetween(rmqc, vhost, source, destination, destinationType)
 	if err != nil {
-		return err
+		return checkDeleted(d, err)
 	}
 
 	log.Printf("[DEBUG] RabbitMQ: Bindings retrieved: %#v", bindings)
@@ -174,51 +165,362 @@ func ReadBinding(d *schema.ResourceData, meta interface{}) error {
 	return nil
 }
 
-func DeleteBinding(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+// UpdateBinding replaces a binding whose routing key or arguments changed.
+// The properties key changes with them, so the new binding is declared before
+// the previous one is deleted, and messages stay routable in between.
+func UpdateBinding(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
 
-	bindingId := strings.Split(d.Id(), "/")
-	if len(bindingId) < 5 {
-		return fmt.Errorf("Unable to determine binding ID")
+	previous, err := parseBindingId(d.Id())
+	if err != nil {
+		return err
 	}
+	vhost := previous.Vhost
 
-	vhost := percentDecodeSlashes(bindingId[0])
-	source := bindingId[1]
-	destination := bindingId[2]
-	destinationType := bindingId[3]
-	propertiesKey := bindingId[4]
+	bindingInfo, err := bindingFromResourceData(d)
+	if err != nil {
+		return err
+	}
 
-	bindingInfo := rabbithole.BindingInfo{
-		Vhost:           vhost,
-		Source:          source,
-		Destination:     destination,
-		DestinationType: destinationType,
-		PropertiesKey:   propertiesKey,
+	propertiesKey, err := declareBinding(rmqc, vhost, bindingInfo)
+	if err != nil {
+		return err
 	}
 
-	log.Printf("[DEBUG] RabbitMQ: Attempting to delete binding for: vhost=%s source=%s destination=%s destinationType=%s propertiesKey=%s",
-		vhost, source, destination, destinationType, propertiesKey)
+	log.Printf("[DEBUG] RabbitMQ: Binding properties key: %s", propertiesKey)
+	bindingInfo.PropertiesKey = propertiesKey
+	bindingInfo.Vhost = vhost
+
+	// Declaring a binding that already exists doesn't change it. The ID only
+	// changes once the previous binding is deleted, so that a failure leaves
+	// it tracked and the next apply retries.
+	if propertiesKey != previous.PropertiesKey {
+		if err := deleteBinding(rmqc, previous); err != nil {
+			return fmt.Errorf("Error deleting previous RabbitMQ binding %s: %s", BindingId(previous), err)
+		}
+	}
 
-	resp, err := rmqc.DeleteBinding(vhost, bindingInfo)
-	if err != nil {
+	d.SetId(BindingId(bindingInfo))
+
+	return ReadBinding(d, meta)
+}
+
+// CustomizeDiffBinding checks the x-match argument and, when the source
+// exchange already exists, that the routing key makes sense for its type
+func CustomizeDiffBinding(d *schema.ResourceDiff, meta interface{}) error {
+	for _, key := range []string{"source", "vhost", "routing_key", "arguments", "arguments_json"} {
+		if !d.NewValueKnown(key) {
+			return nil
+		}
+	}
+
+	arguments := d.Get("arguments").(map[string]interface{})
+	if v, ok := d.GetOk("arguments_json"); ok {
+		if err := json.Unmarshal([]byte(v.(string)), &arguments); err != nil {
+			return err
+		}
+	}
+
+	if err := validateBindingXMatch(arguments); err != nil {
 		return err
 	}
 
-	log.Printf("[DEBUG] RabbitMQ: Binding delete response: %#v", resp)
+	source := d.Get("source").(string)
+	vhost := d.Get("vhost").(string)
+	if source == "" {
+		return nil
+	}
 
-	if resp.StatusCode == 404 {
-		// The binding was already deleted
+	rmqc := meta.(*rabbitmqClient)
+	exchange, err := rmqc.GetExchange(vhost, source)
+	if err != nil {
+		// The exchange or the vhost may not exist yet, and the checks depending
+		// on the type of the exchange are skipped when it can't be read, so
+		// that planning doesn't depend on the broker being reachable.
+		if rmqErr, ok := err.(rabbithole.ErrorResponse); !ok || rmqErr.StatusCode != 404 {
+			log.Printf("[WARN] RabbitMQ: Unable to read exchange %s to check its bindings: %s", source, err)
+		}
 		return nil
 	}
 
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("Error deleting RabbitMQ binding: %s", resp.Status)
+	for _, warning := range bindingWarnings(exchange.Type, d.Get("routing_key").(string), arguments) {
+		log.Printf("[WARN] RabbitMQ: Binding of exchange %s: %s", source, warning)
 	}
 
 	return nil
 }
 
-func declareBinding(rmqc *rabbithole.Client, vhost string, bindingInfo rabbithole.BindingInfo) (string, error) {
+func DeleteBinding(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	bindingInfo, err := parseBindingId(d.Id())
+	if err != nil {
+		return err
+	}
+
+	return deleteBinding(rmqc, bindingInfo)
+}
+
+// Values of the x-match argument of bindings to headers exchanges
+var bindingXMatchValues = []string{"all", "any", "all-with-x", "any-with-x"}
+
+func validateBindingXMatch(arguments map[string]interface{}) error {
+	v, ok := arguments["x-match"]
+	if !ok {
+		return nil
+	}
+
+	for _, value := range bindingXMatchValues {
+		if v == value {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("x-match must be one of %s, got %v", strings.Join(bindingXMatchValues, ", "), v)
+}
+
+// bindingWarnings returns the settings of a binding that are ignored or
+// probably mistaken for the type of its source exchange.
+func bindingWarnings(exchangeType string, routingKey string, arguments map[string]interface{}) []string {
+	var warnings []string
+	switch exchangeType {
+	case "fanout":
+		if routingKey != "" {
+			warnings = append(warnings, fmt.Sprintf("routing key %q is ignored by fanout exchanges", routingKey))
+		}
+	case "headers":
+		if routingKey != "" {
+			warnings = append(warnings, fmt.Sprintf("routing key %q is ignored by headers exchanges", routingKey))
+		}
+		if _, ok := arguments["x-match"]; !ok {
+			warnings = append(warnings, "x-match isn't set, so all headers must match")
+		}
+	case "direct":
+		if strings.ContainsAny(routingKey, "*#") {
+			warnings = append(warnings, fmt.Sprintf("routing key %q only matches literally, wildcards are only supported by topic exchanges", routingKey))
+		}
+	}
+	return warnings
+}
+
+// BindingId builds the ID of a binding. Every component is
+// percent-encoded, since exchange and queue names may contain slashes.
+func BindingId(binding rabbithole.BindingInfo) string {
+	return strings.Join([]string{
+		percentEncodeSlashes(binding.Vhost),
+		percentEncodeSlashes(binding.Source),
+		percentEncodeSlashes(binding.Destination),
+		percentEncodeSlashes(binding.DestinationType),
+		percentEncodeSlashes(binding.PropertiesKey),
+	}, "/")
+}
+
+// parseBindingId parses the ID of a binding, in which every component is
+// percent-encoded.
+func parseBindingId(id string) (rabbithole.BindingInfo, error) {
+	parts := strings.Split(id, "/")
+	log.Printf("[DEBUG] RabbitMQ: binding ID: %#v", parts)
+
+	if len(parts) != 5 {
+		return rabbithole.BindingInfo{}, fmt.Errorf("Unable to determine binding ID: %s", id)
+	}
+
+	return rabbithole.BindingInfo{
+		Vhost:           percentDecodeSlashes(parts[0]),
+		Source:          percentDecodeSlashes(parts[1]),
+		Destination:     percentDecodeSlashes(parts[2]),
+		DestinationType: percentDecodeSlashes(parts[3]),
+		PropertiesKey:   percentDecodeSlashes(parts[4]),
+	}, nil
+}
+
+// parseLegacyBindingId parses the IDs created before every component was
+// encoded, in which only the vhost is. They are accepted as long as the
+// names of the source and destination don't contain slashes.
+func parseLegacyBindingId(id string) (rabbithole.BindingInfo, error) {
+	parts := strings.Split(id, "/")
+	log.Printf("[DEBUG] RabbitMQ: legacy binding ID: %#v", parts)
+
+	// A slash in the routing key, which is part of the properties key,
+	// splits the last component.
+	if len(parts) > 5 && (parts[3] == "queue" || parts[3] == "exchange") {
+		parts = append(parts[:4], strings.Join(parts[4:], "/"))
+	}
+
+	if len(parts) != 5 {
+		return rabbithole.BindingInfo{}, fmt.Errorf("Unable to determine binding ID: %s", id)
+	}
+
+	return rabbithole.BindingInfo{
+		Vhost:           percentDecodeSlashes(parts[0]),
+		Source:          parts[1],
+		Destination:     parts[2],
+		DestinationType: parts[3],
+		PropertiesKey:   parts[4],
+	}, nil
+}
+
+// importBinding accepts both the current and the legacy ID formats, and
+// stores the ID in the current format. When the ID is valid in both formats
+// but they disagree, the legacy format is only used if its binding exists.
+func importBinding(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	rmqc := meta.(*rabbitmqClient)
+
+	legacy, err := parseLegacyBindingId(d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	binding, err := parseBindingId(d.Id())
+	if err != nil {
+		binding = legacy
+	} else if BindingId(binding) != BindingId(legacy) {
+		exists, err := bindingExists(rmqc, binding)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			binding = legacy
+		}
+	}
+
+	d.SetId(BindingId(binding))
+
+	return []*schema.ResourceData{d}, nil
+}
+
+func bindingExists(rmqc *rabbitmqClient, binding rabbithole.BindingInfo) (bool, error) {
+	bindings, err := listBindingsBetween(rmqc, binding.Vhost, binding.Source, binding.Destination, binding.DestinationType)
+	if err != nil {
+		if rmqErr, ok := err.(rabbithole.ErrorResponse); ok && rmqErr.StatusCode == 404 {
+			return false, nil
+		}
+		return false, err
+	}
+
+	for _, b := range bindings {
+		if b.PropertiesKey == binding.PropertiesKey {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func resourceBindingV0() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"source": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"vhost": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"destination": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"destination_type": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"properties_key": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"routing_key": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+
+			"arguments": {
+				Type:     schema.TypeMap,
+				Optional: true,
+			},
+
+			"arguments_json": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+		},
+	}
+}
+
+// resourceBindingStateUpgradeV0 rewrites IDs in which only the vhost was
+// encoded. The ID is rebuilt from the attributes, which are unambiguous
+// even when names contain slashes.
+func resourceBindingStateUpgradeV0(rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	binding := rabbithole.BindingInfo{}
+	for key, value := range map[string]*string{
+		"vhost":            &binding.Vhost,
+		"source":           &binding.Source,
+		"destination":      &binding.Destination,
+		"destination_type": &binding.DestinationType,
+		"properties_key":   &binding.PropertiesKey,
+	} {
+		v, ok := rawState[key].(string)
+		if !ok {
+			// Fall back on the ID when the attributes were never read.
+			id, _ := rawState["id"].(string)
+			parsed, err := parseLegacyBindingId(id)
+			if err != nil {
+				return nil, err
+			}
+			rawState["id"] = BindingId(parsed)
+			return rawState, nil
+		}
+		*value = v
+	}
+
+	rawState["id"] = BindingId(binding)
+
+	return rawState, nil
+}
+
+// listBindingsBetween only lists the bindings between source and destination,
+// so that reading a binding doesn't depend on the number of bindings in the vhost.
+func listBindingsBetween(rmqc *rabbitmqClient, vhost, source, destination, destinationType string) ([]rabbithole.BindingInfo, error) {
+	if destinationType == "queue" {
+		return rmqc.ListQueueBindingsBetween(vhost, source, destination)
+	}
+
+	return rmqc.ListExchangeBindingsBetween(vhost, source, destination)
+}
+
+// bindingFromResourceData returns the binding configured in d, without its
+// vhost and properties key.
+func bindingFromResourceData(d *schema.ResourceData) (rabbithole.BindingInfo, error) {
+	arguments := d.Get("arguments").(map[string]interface{})
+
+	// If arguments_json is used, unmarshal it into a generic interface
+	// and use it as the "arguments" key for the binding.
+	if v, ok := d.Get("arguments_json").(string); ok && v != "" {
+		var arguments_json map[string]interface{}
+		err := json.Unmarshal([]byte(v), &arguments_json)
+		if err != nil {
+			return rabbithole.BindingInfo{}, err
+		}
+
+		arguments = arguments_json
+	}
+
+	return rabbithole.BindingInfo{
+		Source:          d.Get("source").(string),
+		Destination:     d.Get("destination").(string),
+		DestinationType: d.Get("destination_type").(string),
+		RoutingKey:      d.Get("routing_key").(string),
+		Arguments:       arguments,
+	}, nil
+}
+
+func declareBinding(rmqc *rabbitmqClient, vhost string, bindingInfo rabbithole.BindingInfo) (string, error) {
 	log.Printf("[DEBUG] RabbitMQ: Attempting to declare binding for: vhost=%s source=%s destination=%s destinationType=%s",
 		vhost, bindingInfo.Source, bindingInfo.Destination, bindingInfo.DestinationType)
 
@@ -241,3 +543,28 @@ func declareBinding(rmqc *rabbithole.Client, vhost string, bindingInfo rabbithol
 
 	return propertiesKey, nil
 }
+
+func deleteBinding(rmqc *rabbitmqClient, bindingInfo rabbithole.BindingInfo) error {
+	vhost := bindingInfo.Vhost
+
+	log.Printf("[DEBUG] RabbitMQ: Attempting to delete binding for: vhost=%s source=%s destination=%s destinationType=%s propertiesKey=%s",
+		vhost, bindingInfo.Source, bindingInfo.Destination, bindingInfo.DestinationType, bindingInfo.PropertiesKey)
+
+	resp, err := rmqc.DeleteBinding(vhost, bindingInfo)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] RabbitMQ: Binding delete response: %#v", resp)
+
+	if resp.StatusCode == 404 {
+		// The binding was already deleted
+		return nil
+	}
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("Error deleting RabbitMQ binding: %s", resp.Status)
+	}
+
+	return nil
+}
@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
-	"strings"
+	"regexp"
 	"testing"
 
 	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
@@ -49,6 +49,47 @@ func TestAccBinding_jsonArguments(t *testing.T) {
 	})
 }
 
+func TestAccBinding_updateArguments(t *testing.T) {
+	var previous, bindingInfo rabbithole.BindingInfo
+	js := `{"x-match": "all", "foo": "bar"}`
+	updated := `{"x-match": "any", "foo": "bar", "baz": "qux"}`
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccBindingCheckDestroy(bindingInfo),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccBindingConfig_jsonArguments(js),
+				Check: resource.ComposeTestCheckFunc(
+					testAccBindingCheck("rabbitmq_binding.test", &previous),
+					testAccBindingCheckJsonArguments("rabbitmq_binding.test", &previous, js),
+				),
+			},
+			{
+				Config: testAccBindingConfig_jsonArguments(updated),
+				Check: resource.ComposeTestCheckFunc(
+					testAccBindingCheck("rabbitmq_binding.test", &bindingInfo),
+					testAccBindingCheckJsonArguments("rabbitmq_binding.test", &bindingInfo, updated),
+					testAccBindingCheckReplaced(&previous, &bindingInfo),
+				),
+			},
+		},
+	})
+}
+
+func TestAccBinding_invalidXMatch(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccBindingConfig_jsonArguments(`{"x-match": "some"}`),
+				ExpectError: regexp.MustCompile("x-match must be one of all, any, all-with-x, any-with-x, got some"),
+			},
+		},
+	})
+}
+
 func TestAccBinding_slashEscaping(t *testing.T) {
 	var bindingInfo rabbithole.BindingInfo
 	resource.Test(t, resource.TestCase{
@@ -83,6 +124,158 @@ func TestAccBinding_propertiesKey(t *testing.T) {
 	})
 }
 
+func TestParseBindingId(t *testing.T) {
+	var inputs = []struct {
+		id       string
+		expected rabbithole.BindingInfo
+	}{
+		{"test/source/destination/queue/~", rabbithole.BindingInfo{Vhost: "test", Source: "source", Destination: "destination", DestinationType: "queue", PropertiesKey: "~"}},
+		{"%2Fvirtual%2F/test%2Fexchange/test%2Fqueue/queue/%2F%2Fkey~hash", rabbithole.BindingInfo{Vhost: "/virtual/", Source: "test/exchange", Destination: "test/queue", DestinationType: "queue", PropertiesKey: "//key~hash"}},
+		{"%2F/100%25/destination/exchange/key", rabbithole.BindingInfo{Vhost: "/", Source: "100%", Destination: "destination", DestinationType: "exchange", PropertiesKey: "key"}},
+		// Routing key "a/b"
+		{"test/source/destination/queue/a%252Fb", rabbithole.BindingInfo{Vhost: "test", Source: "source", Destination: "destination", DestinationType: "queue", PropertiesKey: "a%2Fb"}},
+	}
+
+	for _, test := range inputs {
+		binding, err := parseBindingId(test.id)
+		if err != nil {
+			t.Errorf("parseBindingId failed for: %s: %s", test.id, err)
+			continue
+		}
+		if !reflect.DeepEqual(binding, test.expected) {
+			t.Errorf("parseBindingId failed for: %s. Got %#v", test.id, binding)
+		}
+		if id := BindingId(binding); id != test.id {
+			t.Errorf("BindingId failed for: %s. Got %s", test.id, id)
+		}
+	}
+
+	for _, id := range []string{"", "test/source/destination", "test/a/b/c/destination/queue", "%2F/source/destination/queue/a/b"} {
+		if _, err := parseBindingId(id); err == nil {
+			t.Errorf("parseBindingId should fail for: %s", id)
+		}
+	}
+}
+
+func TestParseLegacyBindingId(t *testing.T) {
+	var inputs = []struct {
+		id       string
+		expected rabbithole.BindingInfo
+	}{
+		{"%2F/source/destination/queue/~", rabbithole.BindingInfo{Vhost: "/", Source: "source", Destination: "destination", DestinationType: "queue", PropertiesKey: "~"}},
+		// Routing key "a/b", whose properties key is encoded by RabbitMQ
+		{"%2F/source/destination/queue/a%2Fb", rabbithole.BindingInfo{Vhost: "/", Source: "source", Destination: "destination", DestinationType: "queue", PropertiesKey: "a%2Fb"}},
+		{"%2F/source/destination/exchange/100%25", rabbithole.BindingInfo{Vhost: "/", Source: "source", Destination: "destination", DestinationType: "exchange", PropertiesKey: "100%25"}},
+		// Slashes in the routing key split the last component
+		{"%2F/source/destination/queue///routing//key/", rabbithole.BindingInfo{Vhost: "/", Source: "source", Destination: "destination", DestinationType: "queue", PropertiesKey: "//routing//key/"}},
+	}
+
+	for _, test := range inputs {
+		binding, err := parseLegacyBindingId(test.id)
+		if err != nil {
+			t.Errorf("parseLegacyBindingId failed for: %s: %s", test.id, err)
+			continue
+		}
+		if !reflect.DeepEqual(binding, test.expected) {
+			t.Errorf("parseLegacyBindingId failed for: %s. Got %#v", test.id, binding)
+		}
+	}
+
+	for _, id := range []string{"", "test/source/destination", "test/a/b/c/destination/queue"} {
+		if _, err := parseLegacyBindingId(id); err == nil {
+			t.Errorf("parseLegacyBindingId should fail for: %s", id)
+		}
+	}
+}
+
+func TestValidateBindingXMatch(t *testing.T) {
+	var goodInputs = []map[string]interface{}{
+		nil,
+		{"foo": "bar"},
+		{"x-match": "all"},
+		{"x-match": "any-with-x"},
+	}
+
+	for _, arguments := range goodInputs {
+		if err := validateBindingXMatch(arguments); err != nil {
+			t.Errorf("validateBindingXMatch failed for: %v: %s", arguments, err)
+		}
+	}
+
+	var badInputs = []map[string]interface{}{
+		{"x-match": "ALL"},
+		{"x-match": ""},
+		{"x-match": true},
+	}
+
+	for _, arguments := range badInputs {
+		if err := validateBindingXMatch(arguments); err == nil {
+			t.Errorf("validateBindingXMatch should fail for: %v", arguments)
+		}
+	}
+}
+
+func TestBindingWarnings(t *testing.T) {
+	var inputs = []struct {
+		exchangeType string
+		routingKey   string
+		arguments    map[string]interface{}
+		warnings     int
+	}{
+		{"fanout", "", nil, 0},
+		{"fanout", "orders", nil, 1},
+		{"headers", "", map[string]interface{}{"x-match": "any"}, 0},
+		{"headers", "orders", nil, 2},
+		{"direct", "orders", nil, 0},
+		{"direct", "orders.#", nil, 1},
+		{"topic", "orders.*", nil, 0},
+		{"x-custom", "orders.*", nil, 0},
+	}
+
+	for _, test := range inputs {
+		if warnings := bindingWarnings(test.exchangeType, test.routingKey, test.arguments); len(warnings) != test.warnings {
+			t.Errorf("bindingWarnings failed for: %s %q. Got %v", test.exchangeType, test.routingKey, warnings)
+		}
+	}
+}
+
+func TestResourceBindingStateUpgradeV0(t *testing.T) {
+	rawState := map[string]interface{}{
+		"id":               "%2F/test/exchange/test/queue/queue/~",
+		"vhost":            "/",
+		"source":           "test/exchange",
+		"destination":      "test/queue",
+		"destination_type": "queue",
+		"properties_key":   "~",
+	}
+
+	actual, err := resourceBindingStateUpgradeV0(rawState, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := "%2F/test%2Fexchange/test%2Fqueue/queue/~"
+	if actual["id"] != expected {
+		t.Errorf("Expected ID %s, got %s", expected, actual["id"])
+	}
+}
+
+func TestResourceBindingStateUpgradeV0_legacyId(t *testing.T) {
+	rawState := map[string]interface{}{
+		"id": "%2F/source/destination/queue/a%2Fb",
+	}
+
+	actual, err := resourceBindingStateUpgradeV0(rawState, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := "%2F/source/destination/queue/a%252Fb"
+	if actual["id"] != expected {
+		t.Errorf("Expected ID %s, got %s", expected, actual["id"])
+	}
+}
+
 func testAccBindingCheck(rn string, bindingInfo *rabbithole.BindingInfo) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[rn]
@@ -95,16 +288,19 @@ func testAccBindingCheck(rn string, bindingInfo *rabbithole.BindingInfo) resourc
 			return fmt.Errorf("binding id not set")
 		}
 
-		rmqc := testAccProvider.Meta().(*rabbithole.Client)
-		bindingParts := strings.Split(rs.Primary.ID, "/")
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
+		bindingId, err := parseBindingId(rs.Primary.ID)
+		if err != nil {
+			return err
+		}
 
-		bindings, err := rmqc.ListBindingsIn(percentDecodeSlashes(bindingParts[0]))
+		bindings, err := rmqc.ListBindingsIn(bindingId.Vhost)
 		if err != nil {
 			return fmt.Errorf("Error retrieving exchange: %s", err)
 		}
 
 		for _, binding := range bindings {
-			if binding.Source == bindingParts[1] && binding.Destination == bindingParts[2] && binding.DestinationType == bindingParts[3] && binding.PropertiesKey == bindingParts[4] {
+			if binding.Source == bindingId.Source && binding.Destination == bindingId.Destination && binding.DestinationType == bindingId.DestinationType && binding.PropertiesKey == bindingId.PropertiesKey {
 				*bindingInfo = binding
 				return nil
 			}
@@ -140,9 +336,21 @@ func testAccBindingCheckJsonArguments(rn string, bindingInfo *rabbithole.Binding
 	}
 }
 
+// testAccBindingCheckReplaced checks that the binding was declared again with
+// a new properties key and that the previous binding was deleted.
+func testAccBindingCheckReplaced(previous, bindingInfo *rabbithole.BindingInfo) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		if previous.PropertiesKey == bindingInfo.PropertiesKey {
+			return fmt.Errorf("Binding properties key didn't change: %s", bindingInfo.PropertiesKey)
+		}
+
+		return testAccBindingCheckDestroy(*previous)(s)
+	}
+}
+
 func testAccBindingCheckDestroy(bindingInfo rabbithole.BindingInfo) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
-		rmqc := testAccProvider.Meta().(*rabbithole.Client)
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
 
 		bindings, err := rmqc.ListBindingsIn(bindingInfo.Vhost)
 		if err != nil {
@@ -268,7 +476,7 @@ resource "rabbitmq_permissions" "guest" {
 }
 
 resource "rabbitmq_exchange" "test" {
-    name = "test"
+    name = "test/exchange"
     vhost = "${rabbitmq_permissions.guest.vhost}"
     settings {
         type = "topic"
@@ -278,7 +486,7 @@ resource "rabbitmq_exchange" "test" {
 }
 
 resource "rabbitmq_queue" "test" {
-    name = "test"
+    name = "test/queue"
     vhost = "${rabbitmq_permissions.guest.vhost}"
     settings {
         durable = true
@@ -0,0 +1,128 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+)
+
+// resourceDefinitions uploads a definitions document, as exported by
+// /api/definitions. RabbitMQ only adds or updates the objects of a document,
+// so objects removed from the document, or the document itself, are never
+// deleted from the broker.
+func resourceDefinitions() *schema.Resource {
+	return &schema.Resource{
+		Create: CreateDefinitions,
+		Update: UpdateDefinitions,
+		Read:   ReadDefinitions,
+		Delete: DeleteDefinitions,
+
+		Schema: map[string]*schema.Schema{
+			"vhost": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+
+			"definitions": {
+				Type:             schema.TypeString,
+				Required:         true,
+				Sensitive:        true,
+				ValidateFunc:     validation.ValidateJsonString,
+				DiffSuppressFunc: suppressDefinitionsDiff,
+			},
+		},
+	}
+}
+
+func CreateDefinitions(d *schema.ResourceData, meta interface{}) error {
+	vhost := d.Get("vhost").(string)
+
+	if err := putDefinitions(meta.(*rabbitmqClient), vhost, d.Get("definitions").(string)); err != nil {
+		return err
+	}
+
+	d.SetId(definitionsId(vhost))
+
+	return ReadDefinitions(d, meta)
+}
+
+// ReadDefinitions reads the objects declared by the document, so that
+// changes made to them outside of Terraform show up as a diff.
+func ReadDefinitions(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	vhost := d.Get("vhost").(string)
+
+	current, err := getDefinitions(rmqc, vhost)
+	if err != nil {
+		return checkDeleted(d, err)
+	}
+
+	declared, err := parseDefinitions(d.Get("definitions").(string))
+	if err != nil {
+		return err
+	}
+
+	projected := projectDefinitions(normaliseDefinitions(declared, vhost), normaliseDefinitions(current, vhost))
+
+	bytes, err := json.Marshal(projected)
+	if err != nil {
+		return fmt.Errorf("could not encode definitions as JSON: %w", err)
+	}
+
+	// Definitions hold password hashes and shovel and federation URIs.
+	log.Printf("[DEBUG] RabbitMQ: Definitions retrieved for %s: %s", d.Id(), redactBody(bytes))
+
+	d.Set("definitions", string(bytes))
+
+	return nil
+}
+
+func UpdateDefinitions(d *schema.ResourceData, meta interface{}) error {
+	if d.HasChange("definitions") {
+		if err := putDefinitions(meta.(*rabbitmqClient), d.Get("vhost").(string), d.Get("definitions").(string)); err != nil {
+			return err
+		}
+	}
+
+	return ReadDefinitions(d, meta)
+}
+
+// DeleteDefinitions only removes the document from the state, since the
+// objects it declares may be managed by other documents or resources.
+func DeleteDefinitions(d *schema.ResourceData, meta interface{}) error {
+	log.Printf("[DEBUG] RabbitMQ: Removing definitions %s from the state, the objects they declare are kept", d.Id())
+	return nil
+}
+
+func putDefinitions(rmqc *rabbitmqClient, vhost string, document string) error {
+	definitions, err := parseDefinitions(document)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] RabbitMQ: Attempting to upload definitions for %s", definitionsId(vhost))
+
+	resp, err := rmqc.executeRequest("POST", definitionsPath(vhost), definitions)
+	if err != nil {
+		return fmt.Errorf("Error uploading RabbitMQ definitions: %s", err)
+	}
+	resp.Body.Close()
+
+	log.Printf("[DEBUG] RabbitMQ: Definitions upload response: %#v", resp)
+
+	return nil
+}
+
+// definitionsId returns the ID of the definitions of a vhost, or of the
+// whole broker.
+func definitionsId(vhost string) string {
+	if vhost == "" {
+		return "*"
+	}
+	return vhost
+}
@@ -0,0 +1,97 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+func TestAccDefinitions_vhost(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccVhostCheckDestroy("test"),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDefinitionsConfig_vhost("false"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccDefinitionsCheckQueue("test", "orders", false),
+					resource.TestCheckResourceAttrSet("data.rabbitmq_definitions.test", "definitions"),
+				),
+			},
+			{
+				// The queue is changed outside of Terraform.
+				PreConfig: func() {
+					rmqc := testAccProvider.Meta().(*rabbitmqClient)
+					if _, err := rmqc.DeleteQueue("test", "orders"); err != nil {
+						t.Fatal(err)
+					}
+				},
+				Config:             testAccDefinitionsConfig_vhost("false"),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: testAccDefinitionsConfig_vhost("true"),
+				Check:  testAccDefinitionsCheckQueue("test", "orders", true),
+			},
+		},
+	})
+}
+
+func testAccDefinitionsCheckQueue(vhost, name string, durable bool) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
+
+		queue, err := rmqc.GetQueue(vhost, name)
+		if err != nil {
+			return fmt.Errorf("Error retrieving queue: %s", err)
+		}
+
+		if queue.Durable != durable {
+			return fmt.Errorf("Queue %s is durable: %t, expected %t", name, queue.Durable, durable)
+		}
+
+		return nil
+	}
+}
+
+func testAccDefinitionsConfig_vhost(durable string) string {
+	return fmt.Sprintf(`
+resource "rabbitmq_vhost" "test" {
+    name = "test"
+}
+
+resource "rabbitmq_permissions" "guest" {
+    user = "guest"
+    vhost = "${rabbitmq_vhost.test.name}"
+    permissions {
+        configure = ".*"
+        write = ".*"
+        read = ".*"
+    }
+}
+
+resource "rabbitmq_definitions" "test" {
+    vhost = "${rabbitmq_permissions.guest.vhost}"
+    definitions = <<EOF
+{
+  "queues": [
+    {"name": "orders", "durable": %s, "auto_delete": false, "arguments": {}}
+  ],
+  "exchanges": [
+    {"name": "events", "type": "topic", "durable": true, "auto_delete": false, "internal": false, "arguments": {}}
+  ],
+  "bindings": [
+    {"source": "events", "destination": "orders", "destination_type": "queue", "routing_key": "orders.#", "arguments": {}}
+  ]
+}
+EOF
+}
+
+data "rabbitmq_definitions" "test" {
+    vhost = "${rabbitmq_definitions.test.vhost}"
+}`, durable)
+}
@@ -3,7 +3,6 @@ package rabbitmq
 import (
 	"fmt"
 	"log"
-	"strings"
 
 	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
 
@@ -11,14 +10,16 @@ import (
 )
 
 func resourceExchange() *schema.Resource {
-	return &schema.Resource{
+	r := &schema.Resource{
 		Create: CreateExchange,
 		Read:   ReadExchange,
 		Delete: DeleteExchange,
 		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+			State: importResourceId,
 		},
 
+		SchemaVersion: 1,
+
 		Schema: map[string]*schema.Schema{
 			"name": {
 				Type:     schema.TypeString,
@@ -64,12 +65,28 @@ func resourceExchange() *schema.Resource {
 					},
 				},
 			},
+
+			"effective_policy": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"effective_policy_definition": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
 		},
 	}
+
+	r.StateUpgraders = []schema.StateUpgrader{
+		resourceIdStateUpgraderV0(r, "name"),
+	}
+
+	return r
 }
 
 func CreateExchange(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
 	name := d.Get("name").(string)
 	vhost := d.Get("vhost").(string)
@@ -84,24 +101,22 @@ func CreateExchange(d *schema.ResourceData, meta interface{}) error {
 		return err
 	}
 
-	id := fmt.Sprintf("%s@%s", name, vhost)
+	id := ResourceId(name, vhost)
 	d.SetId(id)
 
 	return ReadExchange(d, meta)
 }
 
 func ReadExchange(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
-	exchangeId := strings.Split(d.Id(), "@")
-	if len(exchangeId) < 2 {
-		return fmt.Errorf("Unable to determine exchange ID")
+	name, vhost, err := parseResourceId(d)
+	if err != nil {
+		return err
 	}
 
-	name := exchangeId[0]
-	vhost := exchangeId[1]
-
-	exchangeSettings, err := rmqc.GetExchange(vhost, name)
+	exchangeSettings := &rabbithole.DetailedExchangeInfo{}
+	policyInfo, err := getPolicyTarget(rmqc, "exchanges", vhost, name, exchangeSettings)
 	if err != nil {
 		return checkDeleted(d, err)
 	}
@@ -120,20 +135,17 @@ func ReadExchange(d *schema.ResourceData, meta interface{}) error {
 	exchange[0] = e
 	d.Set("settings", exchange)
 
-	return nil
+	return setEffectivePolicy(rmqc, d, policyInfo)
 }
 
 func DeleteExchange(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
-	exchangeId := strings.Split(d.Id(), "@")
-	if len(exchangeId) < 2 {
-		return fmt.Errorf("Unable to determine exchange ID")
+	name, vhost, err := parseResourceId(d)
+	if err != nil {
+		return err
 	}
 
-	name := exchangeId[0]
-	vhost := exchangeId[1]
-
 	log.Printf("[DEBUG] RabbitMQ: Attempting to delete exchange %s", d.Id())
 
 	resp, err := rmqc.DeleteExchange(vhost, name)
@@ -154,7 +166,7 @@ func DeleteExchange(d *schema.ResourceData, meta interface{}) error {
 	return nil
 }
 
-func declareExchange(rmqc *rabbithole.Client, vhost string, name string, settingsMap map[string]interface{}) error {
+func declareExchange(rmqc *rabbitmqClient, vhost string, name string, settingsMap map[string]interface{}) error {
 	exchangeSettings := rabbithole.ExchangeSettings{}
 
 	if v, ok := settingsMap["type"].(string); ok {
@@ -0,0 +1,144 @@
+package rabbitmq
+
+import (
+	"log"
+
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+)
+
+// resourceExchangeBindings owns every binding whose source is the exchange:
+// bindings missing from the configuration are deleted.
+func resourceExchangeBindings() *schema.Resource {
+	return &schema.Resource{
+		Create: CreateExchangeBindings,
+		Update: UpdateExchangeBindings,
+		Read:   ReadExchangeBindings,
+		Delete: DeleteExchangeBindings,
+		Importer: &schema.ResourceImporter{
+			State: importResourceId,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"source": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+
+			"vhost": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+
+			"binding": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem: bindingSetElem(map[string]*schema.Schema{
+					"destination": {
+						Type:     schema.TypeString,
+						Required: true,
+					},
+
+					"destination_type": {
+						Type:         schema.TypeString,
+						Required:     true,
+						ValidateFunc: validation.StringInSlice([]string{"queue", "exchange"}, false),
+					},
+				}),
+			},
+		},
+	}
+}
+
+func CreateExchangeBindings(d *schema.ResourceData, meta interface{}) error {
+	source := d.Get("source").(string)
+	vhost := d.Get("vhost").(string)
+
+	if err := putExchangeBindings(d, meta, source, vhost); err != nil {
+		return err
+	}
+
+	d.SetId(ResourceId(source, vhost))
+
+	return ReadExchangeBindings(d, meta)
+}
+
+func ReadExchangeBindings(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	source, vhost, err := parseResourceId(d)
+	if err != nil {
+		return err
+	}
+
+	bindings, err := rmqc.ListExchangeBindingsWithSource(vhost, source)
+	if err != nil {
+		return checkDeleted(d, err)
+	}
+
+	log.Printf("[DEBUG] RabbitMQ: Bindings retrieved for exchange %s: %#v", d.Id(), bindings)
+
+	d.Set("source", source)
+	d.Set("vhost", vhost)
+
+	set := make([]map[string]interface{}, 0, len(bindings))
+	for _, binding := range bindings {
+		set = append(set, map[string]interface{}{
+			"destination":      binding.Destination,
+			"destination_type": binding.DestinationType,
+			"routing_key":      binding.RoutingKey,
+			"arguments":        bindingArgumentsToMap(binding.Arguments),
+		})
+	}
+
+	return d.Set("binding", set)
+}
+
+func UpdateExchangeBindings(d *schema.ResourceData, meta interface{}) error {
+	source, vhost, err := parseResourceId(d)
+	if err != nil {
+		return err
+	}
+
+	if err := putExchangeBindings(d, meta, source, vhost); err != nil {
+		return err
+	}
+
+	return ReadExchangeBindings(d, meta)
+}
+
+func DeleteExchangeBindings(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	source, vhost, err := parseResourceId(d)
+	if err != nil {
+		return err
+	}
+
+	actual, err := rmqc.ListExchangeBindingsWithSource(vhost, source)
+	if err != nil {
+		return checkDeleted(d, err)
+	}
+
+	// Only the bindings of the configuration are deleted.
+	managed := bindingsFromSet(d.Get("binding").(*schema.Set), rabbithole.BindingInfo{Source: source})
+
+	return deleteBindings(rmqc, vhost, managed, actual)
+}
+
+func putExchangeBindings(d *schema.ResourceData, meta interface{}, source, vhost string) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	actual, err := rmqc.ListExchangeBindingsWithSource(vhost, source)
+	if err != nil {
+		return err
+	}
+
+	desired := bindingsFromSet(d.Get("binding").(*schema.Set), rabbithole.BindingInfo{Source: source})
+
+	return reconcileBindings(rmqc, vhost, desired, actual)
+}
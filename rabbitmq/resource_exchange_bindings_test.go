@@ -0,0 +1,199 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"testing"
+
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+func TestAccExchangeBindings_basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccExchangeBindingsCheckDestroy("test", "test"),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccExchangeBindingsConfig_basic,
+				Check: testAccExchangeBindingsCheck(
+					"rabbitmq_exchange_bindings.test", "orders.#", "invoices.#",
+				),
+			},
+			{
+				// A binding declared outside of Terraform is removed.
+				PreConfig: func() {
+					rmqc := testAccProvider.Meta().(*rabbitmqClient)
+					if _, err := declareBinding(rmqc, "test", rabbithole.BindingInfo{
+						Source:          "test",
+						Destination:     "b",
+						DestinationType: "queue",
+						RoutingKey:      "stray",
+					}); err != nil {
+						t.Fatal(err)
+					}
+				},
+				Config: testAccExchangeBindingsConfig_basic,
+				Check: testAccExchangeBindingsCheck(
+					"rabbitmq_exchange_bindings.test", "orders.#", "invoices.#",
+				),
+			},
+			{
+				Config: testAccExchangeBindingsConfig_update,
+				Check: testAccExchangeBindingsCheck(
+					"rabbitmq_exchange_bindings.test", "orders.created",
+				),
+			},
+		},
+	})
+}
+
+// testAccExchangeBindingsCheck checks that the bindings of the exchange have
+// exactly the given routing keys.
+func testAccExchangeBindingsCheck(rn string, routingKeys ...string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[rn]
+		if !ok {
+			return fmt.Errorf("resource not found: %s", rn)
+		}
+
+		if rs.Primary.ID == "" {
+			return fmt.Errorf("exchange bindings id not set")
+		}
+
+		source, vhost, err := parseId(rs.Primary.ID)
+		if err != nil {
+			return err
+		}
+
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
+		bindings, err := rmqc.ListExchangeBindingsWithSource(vhost, source)
+		if err != nil {
+			return fmt.Errorf("Error retrieving bindings: %s", err)
+		}
+
+		return testAccCheckBindingRoutingKeys(bindings, routingKeys)
+	}
+}
+
+func testAccCheckBindingRoutingKeys(bindings []rabbithole.BindingInfo, routingKeys []string) error {
+	found := map[string]bool{}
+	for _, binding := range bindings {
+		if bindingIgnored(binding) {
+			continue
+		}
+		found[binding.RoutingKey] = true
+	}
+
+	for _, key := range routingKeys {
+		if !found[key] {
+			return fmt.Errorf("Unable to find binding with routing key %s in %#v", key, bindings)
+		}
+		delete(found, key)
+	}
+
+	if len(found) > 0 {
+		return fmt.Errorf("Unexpected bindings: %#v", found)
+	}
+
+	return nil
+}
+
+func testAccExchangeBindingsCheckDestroy(source, vhost string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
+
+		bindings, err := rmqc.ListExchangeBindingsWithSource(vhost, source)
+		if err != nil {
+			if rmqErr, ok := err.(rabbithole.ErrorResponse); ok && rmqErr.StatusCode == 404 {
+				return nil
+			}
+			return fmt.Errorf("Error retrieving bindings: %s", err)
+		}
+
+		if len(bindings) > 0 {
+			return fmt.Errorf("Bindings still exist: %#v", bindings)
+		}
+
+		return nil
+	}
+}
+
+const testAccExchangeBindingsConfig_base = `
+resource "rabbitmq_vhost" "test" {
+    name = "test"
+}
+
+resource "rabbitmq_permissions" "guest" {
+    user = "guest"
+    vhost = "${rabbitmq_vhost.test.name}"
+    permissions {
+        configure = ".*"
+        write = ".*"
+        read = ".*"
+    }
+}
+
+resource "rabbitmq_exchange" "test" {
+    name = "test"
+    vhost = "${rabbitmq_permissions.guest.vhost}"
+    settings {
+        type = "topic"
+        durable = false
+        auto_delete = false
+    }
+}
+
+resource "rabbitmq_queue" "a" {
+    name = "a"
+    vhost = "${rabbitmq_permissions.guest.vhost}"
+    settings {
+        durable = false
+        auto_delete = false
+    }
+}
+
+resource "rabbitmq_queue" "b" {
+    name = "b"
+    vhost = "${rabbitmq_permissions.guest.vhost}"
+    settings {
+        durable = false
+        auto_delete = false
+    }
+}
+`
+
+const testAccExchangeBindingsConfig_basic = testAccExchangeBindingsConfig_base + `
+resource "rabbitmq_exchange_bindings" "test" {
+    source = "${rabbitmq_exchange.test.name}"
+    vhost = "${rabbitmq_vhost.test.name}"
+
+    binding {
+        destination = "${rabbitmq_queue.a.name}"
+        destination_type = "queue"
+        routing_key = "orders.#"
+    }
+
+    binding {
+        destination = "${rabbitmq_queue.b.name}"
+        destination_type = "queue"
+        routing_key = "invoices.#"
+        arguments = {
+            foo = "bar"
+        }
+    }
+}`
+
+const testAccExchangeBindingsConfig_update = testAccExchangeBindingsConfig_base + `
+resource "rabbitmq_exchange_bindings" "test" {
+    source = "${rabbitmq_exchange.test.name}"
+    vhost = "${rabbitmq_vhost.test.name}"
+
+    binding {
+        destination = "${rabbitmq_queue.a.name}"
+        destination_type = "queue"
+        routing_key = "orders.created"
+    }
+}`
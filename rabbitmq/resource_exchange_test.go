@@ -2,7 +2,6 @@ package rabbitmq
 
 import (
 	"fmt"
-	"strings"
 	"testing"
 
 	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
@@ -39,16 +38,19 @@ func testAccExchangeCheck(rn string, exchangeInfo *rabbithole.ExchangeInfo) reso
 			return fmt.Errorf("exchange id not set")
 		}
 
-		rmqc := testAccProvider.Meta().(*rabbithole.Client)
-		exchParts := strings.Split(rs.Primary.ID, "@")
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
+		name, vhost, err := parseId(rs.Primary.ID)
+		if err != nil {
+			return err
+		}
 
-		exchanges, err := rmqc.ListExchangesIn(exchParts[1])
+		exchanges, err := rmqc.ListExchangesIn(vhost)
 		if err != nil {
 			return fmt.Errorf("Error retrieving exchange: %s", err)
 		}
 
 		for _, exchange := range exchanges {
-			if exchange.Name == exchParts[0] && exchange.Vhost == exchParts[1] {
+			if exchange.Name == name && exchange.Vhost == vhost {
 				exchangeInfo = &exchange
 				return nil
 			}
@@ -60,7 +62,7 @@ func testAccExchangeCheck(rn string, exchangeInfo *rabbithole.ExchangeInfo) reso
 
 func testAccExchangeCheckDestroy(exchangeInfo *rabbithole.ExchangeInfo) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
-		rmqc := testAccProvider.Meta().(*rabbithole.Client)
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
 
 		exchanges, err := rmqc.ListExchangesIn(exchangeInfo.Vhost)
 		if err != nil {
@@ -10,15 +10,17 @@ import (
 )
 
 func resourceFederationUpstream() *schema.Resource {
-	return &schema.Resource{
+	r := &schema.Resource{
 		Create: CreateFederationUpstream,
 		Read:   ReadFederationUpstream,
 		Update: UpdateFederationUpstream,
 		Delete: DeleteFederationUpstream,
 		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+			State: importResourceId,
 		},
 
+		SchemaVersion: 1,
+
 		Schema: map[string]*schema.Schema{
 			"name": {
 				Type:     schema.TypeString,
@@ -110,10 +112,16 @@ func resourceFederationUpstream() *schema.Resource {
 			},
 		},
 	}
+
+	r.StateUpgraders = []schema.StateUpgrader{
+		resourceIdStateUpgraderV0(r, "name"),
+	}
+
+	return r
 }
 
 func CreateFederationUpstream(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
 	name := d.Get("name").(string)
 	vhost := d.Get("vhost").(string)
@@ -128,14 +136,14 @@ func CreateFederationUpstream(d *schema.ResourceData, meta interface{}) error {
 		return err
 	}
 
-	id := fmt.Sprintf("%s@%s", name, vhost)
+	id := ResourceId(name, vhost)
 	d.SetId(id)
 
 	return ReadFederationUpstream(d, meta)
 }
 
 func ReadFederationUpstream(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
 	name, vhost, err := parseResourceId(d)
 	if err != nil {
@@ -147,7 +155,9 @@ func ReadFederationUpstream(d *schema.ResourceData, meta interface{}) error {
 		return checkDeleted(d, err)
 	}
 
-	log.Printf("[DEBUG] RabbitMQ: Federation upstream retrieved for %s: %#v", d.Id(), upstream)
+	logged := *upstream
+	logged.Definition.Uri = RedactURI(logged.Definition.Uri)
+	log.Printf("[DEBUG] RabbitMQ: Federation upstream retrieved for %s: %#v", d.Id(), logged)
 
 	d.Set("name", upstream.Name)
 	d.Set("vhost", upstream.Vhost)
@@ -173,7 +183,7 @@ func ReadFederationUpstream(d *schema.ResourceData, meta interface{}) error {
 }
 
 func UpdateFederationUpstream(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
 	name, vhost, err := parseResourceId(d)
 	if err != nil {
@@ -198,7 +208,7 @@ func UpdateFederationUpstream(d *schema.ResourceData, meta interface{}) error {
 }
 
 func DeleteFederationUpstream(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
 	name, vhost, err := parseResourceId(d)
 	if err != nil {
@@ -225,10 +235,30 @@ func DeleteFederationUpstream(d *schema.ResourceData, meta interface{}) error {
 	return nil
 }
 
-func putFederationUpstream(rmqc *rabbithole.Client, vhost string, name string, defMap map[string]interface{}) error {
-	definition := rabbithole.FederationDefinition{}
+func putFederationUpstream(rmqc *rabbitmqClient, vhost string, name string, defMap map[string]interface{}) error {
+	definition := FederationDefinition(defMap)
+
+	logged := definition
+	logged.Uri = RedactURI(logged.Uri)
+	log.Printf("[DEBUG] RabbitMQ: Attempting to declare federation upstream for %s@%s: %#v", name, vhost, logged)
+
+	resp, err := rmqc.PutFederationUpstream(vhost, name, definition)
+	log.Printf("[DEBUG] RabbitMQ: Federation upstream declare response: %#v", resp)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("Error creating RabbitMQ federation upstream: %s", resp.Status)
+	}
 
-	log.Printf("[DEBUG] RabbitMQ: Attempting to put federation definition for %s@%s: %#v", name, vhost, defMap)
+	return nil
+}
+
+// FederationDefinition returns the definition of a definition block, as it
+// is declared in RabbitMQ.
+func FederationDefinition(defMap map[string]interface{}) rabbithole.FederationDefinition {
+	definition := rabbithole.FederationDefinition{}
 
 	if v, ok := defMap["uri"].(string); ok {
 		definition.Uri = v
@@ -270,17 +300,5 @@ func putFederationUpstream(rmqc *rabbithole.Client, vhost string, name string, d
 		definition.Queue = v
 	}
 
-	log.Printf("[DEBUG] RabbitMQ: Attempting to declare federation upstream for %s@%s: %#v", name, vhost, definition)
-
-	resp, err := rmqc.PutFederationUpstream(vhost, name, definition)
-	log.Printf("[DEBUG] RabbitMQ: Federation upstream declare response: %#v", resp)
-	if err != nil {
-		return err
-	}
-
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("Error creating RabbitMQ federation upstream: %s", resp.Status)
-	}
-
-	return nil
+	return definition
 }
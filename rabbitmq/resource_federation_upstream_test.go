@@ -3,7 +3,6 @@ package rabbitmq
 import (
 	"fmt"
 	"regexp"
-	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
@@ -123,11 +122,12 @@ func testAccFederationUpstreamCheck(rn string, upstream *rabbithole.FederationUp
 			return fmt.Errorf("federation upstream id not set")
 		}
 
-		id := strings.Split(rs.Primary.ID, "@")
-		name := id[0]
-		vhost := id[1]
+		name, vhost, err := parseId(rs.Primary.ID)
+		if err != nil {
+			return err
+		}
 
-		rmqc := testAccProvider.Meta().(*rabbithole.Client)
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
 		upstreams, err := rmqc.ListFederationUpstreamsIn(vhost)
 		if err != nil {
 			return fmt.Errorf("Error retrieving federation upstreams: %s", err)
@@ -146,7 +146,7 @@ func testAccFederationUpstreamCheck(rn string, upstream *rabbithole.FederationUp
 
 func testAccFederationUpstreamCheckDestroy(upstream *rabbithole.FederationUpstream) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
-		rmqc := testAccProvider.Meta().(*rabbithole.Client)
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
 
 		upstreams, err := rmqc.ListFederationUpstreamsIn(upstream.Vhost)
 		if err != nil {
@@ -3,7 +3,7 @@ package rabbitmq
 import (
 	"fmt"
 	"log"
-	"strings"
+	"net/url"
 
 	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
 
@@ -11,15 +11,17 @@ import (
 )
 
 func resourcePermissions() *schema.Resource {
-	return &schema.Resource{
+	r := &schema.Resource{
 		Create: CreatePermissions,
 		Update: UpdatePermissions,
 		Read:   ReadPermissions,
 		Delete: DeletePermissions,
 		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+			State: importResourceId,
 		},
 
+		SchemaVersion: 1,
+
 		Schema: map[string]*schema.Schema{
 			"user": {
 				Type:     schema.TypeString,
@@ -41,28 +43,37 @@ func resourcePermissions() *schema.Resource {
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"configure": {
-							Type:     schema.TypeString,
-							Required: true,
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validatePermissionRegexp(false),
 						},
 
 						"write": {
-							Type:     schema.TypeString,
-							Required: true,
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validatePermissionRegexp(false),
 						},
 
 						"read": {
-							Type:     schema.TypeString,
-							Required: true,
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validatePermissionRegexp(false),
 						},
 					},
 				},
 			},
 		},
 	}
+
+	r.StateUpgraders = []schema.StateUpgrader{
+		resourceIdStateUpgraderV0(r, "user"),
+	}
+
+	return r
 }
 
 func CreatePermissions(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
 	user := d.Get("user").(string)
 	vhost := d.Get("vhost").(string)
@@ -77,23 +88,20 @@ func CreatePermissions(d *schema.ResourceData, meta interface{}) error {
 		return err
 	}
 
-	id := fmt.Sprintf("%s@%s", user, vhost)
+	id := ResourceId(user, vhost)
 	d.SetId(id)
 
 	return ReadPermissions(d, meta)
 }
 
 func ReadPermissions(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
-	permissionId := strings.Split(d.Id(), "@")
-	if len(permissionId) < 2 {
-		return fmt.Errorf("Unable to determine Permission ID")
+	user, vhost, err := parseResourceId(d)
+	if err != nil {
+		return err
 	}
 
-	user := permissionId[0]
-	vhost := permissionId[1]
-
 	userPerms, err := rmqc.GetPermissionsIn(vhost, user)
 	if err != nil {
 		return checkDeleted(d, err)
@@ -116,9 +124,9 @@ func ReadPermissions(d *schema.ResourceData, meta interface{}) error {
 }
 
 func UpdatePermissions(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
-	user, vhost, err := parseID(d)
+	user, vhost, err := parseResourceId(d)
 	if err != nil {
 		return err
 	}
@@ -141,14 +149,18 @@ func UpdatePermissions(d *schema.ResourceData, meta interface{}) error {
 }
 
 func DeletePermissions(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
-	user, vhost, err := parseID(d)
+	user, vhost, err := parseResourceId(d)
 	if err != nil {
 		return err
 	}
 
-	log.Printf("[DEBUG] RabbitMQ: Attempting to delete permission for %s", d.Id())
+	return clearPermissionsIn(rmqc, vhost, user)
+}
+
+func clearPermissionsIn(rmqc *rabbitmqClient, vhost string, user string) error {
+	log.Printf("[DEBUG] RabbitMQ: Attempting to delete permission for %s@%s", user, vhost)
 
 	resp, err := rmqc.ClearPermissionsIn(vhost, user)
 	log.Printf("[DEBUG] RabbitMQ: Permission delete response: %#v", resp)
@@ -168,7 +180,7 @@ func DeletePermissions(d *schema.ResourceData, meta interface{}) error {
 	return nil
 }
 
-func setPermissionsIn(rmqc *rabbithole.Client, vhost string, user string, permsMap map[string]interface{}) error {
+func setPermissionsIn(rmqc *rabbitmqClient, vhost string, user string, permsMap map[string]interface{}) error {
 	perms := rabbithole.Permissions{}
 
 	if v, ok := permsMap["configure"].(string); ok {
@@ -198,10 +210,10 @@ func setPermissionsIn(rmqc *rabbithole.Client, vhost string, user string, permsM
 	return nil
 }
 
-func parseID(d *schema.ResourceData) (string, string, error) {
-	ID := strings.Split(d.Id(), "@")
-	if len(ID) < 2 {
-		return "", "", fmt.Errorf("Unable to determine Permission ID")
-	}
-	return ID[0], ID[1], nil
+// listPermissionsIn lists the permissions granted in a vhost, which the
+// version of rabbit-hole in use has no method for.
+func listPermissionsIn(rmqc *rabbitmqClient, vhost string) ([]rabbithole.PermissionInfo, error) {
+	var rec []rabbithole.PermissionInfo
+	err := rmqc.executeAndParseRequest("vhosts/"+url.PathEscape(vhost)+"/permissions", &rec)
+	return rec, err
 }
@@ -2,7 +2,6 @@ package rabbitmq
 
 import (
 	"fmt"
-	"strings"
 	"testing"
 
 	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
@@ -82,15 +81,18 @@ func testAccPermissionsCheck(rn string, permissionInfo *rabbithole.PermissionInf
 			return fmt.Errorf("permission id not set")
 		}
 
-		rmqc := testAccProvider.Meta().(*rabbithole.Client)
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
 		perms, err := rmqc.ListPermissions()
 		if err != nil {
 			return fmt.Errorf("Error retrieving permissions: %s", err)
 		}
 
-		userParts := strings.Split(rs.Primary.ID, "@")
+		user, vhost, err := parseId(rs.Primary.ID)
+		if err != nil {
+			return err
+		}
 		for _, perm := range perms {
-			if perm.User == userParts[0] && perm.Vhost == userParts[1] {
+			if perm.User == user && perm.Vhost == vhost {
 				permissionInfo = &perm
 				return nil
 			}
@@ -102,7 +104,7 @@ func testAccPermissionsCheck(rn string, permissionInfo *rabbithole.PermissionInf
 
 func testAccPermissionsCheckDestroy(permissionInfo *rabbithole.PermissionInfo) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
-		rmqc := testAccProvider.Meta().(*rabbithole.Client)
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
 		perms, err := rmqc.ListPermissions()
 		if err != nil {
 			return fmt.Errorf("Error retrieving permissions: %s", err)
@@ -1,26 +1,34 @@
 package rabbitmq
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net/url"
 	"strconv"
 	"strings"
 
 	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/structure"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
 )
 
 func resourcePolicy() *schema.Resource {
-	return &schema.Resource{
-		Create: CreatePolicy,
-		Update: UpdatePolicy,
-		Read:   ReadPolicy,
-		Delete: DeletePolicy,
+	r := &schema.Resource{
+		Create:        CreatePolicy,
+		Update:        UpdatePolicy,
+		Read:          ReadPolicy,
+		Delete:        DeletePolicy,
+		CustomizeDiff: CustomizeDiffPolicy,
 		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+			State: importResourceId,
 		},
 
+		SchemaVersion: 1,
+
 		Schema: map[string]*schema.Schema{
 			"name": {
 				Type:     schema.TypeString,
@@ -51,23 +59,46 @@ func resourcePolicy() *schema.Resource {
 						},
 
 						"apply_to": {
-							Type:     schema.TypeString,
-							Required: true,
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringInSlice(policyApplyToValues(), false),
 						},
 
 						"definition": {
-							Type:     schema.TypeMap,
-							Required: true,
+							Type:          schema.TypeMap,
+							Optional:      true,
+							ValidateFunc:  validatePolicyDefinitionKeys,
+							ConflictsWith: []string{"policy.0.definition_json"},
+						},
+
+						"definition_json": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							ValidateFunc:     validatePolicyDefinitionJson,
+							ConflictsWith:    []string{"policy.0.definition"},
+							DiffSuppressFunc: structure.SuppressJsonDiff,
 						},
 					},
 				},
 			},
+
+			"overlap_check": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{policyOverlapWarn, policyOverlapError}, false),
+			},
 		},
 	}
+
+	r.StateUpgraders = []schema.StateUpgrader{
+		resourceIdStateUpgraderV0(r, "name"),
+	}
+
+	return r
 }
 
 func CreatePolicy(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
 	name := d.Get("name").(string)
 	vhost := d.Get("vhost").(string)
@@ -82,24 +113,21 @@ func CreatePolicy(d *schema.ResourceData, meta interface{}) error {
 		return err
 	}
 
-	id := fmt.Sprintf("%s@%s", name, vhost)
+	id := ResourceId(name, vhost)
 	d.SetId(id)
 
 	return ReadPolicy(d, meta)
 }
 
 func ReadPolicy(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
-	policyId := strings.Split(d.Id(), "@")
-	if len(policyId) < 2 {
-		return fmt.Errorf("Unable to determine policy ID")
+	name, vhost, err := parseResourceId(d)
+	if err != nil {
+		return err
 	}
 
-	name := policyId[0]
-	vhost := policyId[1]
-
-	policy, err := rmqc.GetPolicy(vhost, name)
+	policy, err := getPolicy(rmqc, vhost, name)
 	if err != nil {
 		return checkDeleted(d, err)
 	}
@@ -115,23 +143,16 @@ func ReadPolicy(d *schema.ResourceData, meta interface{}) error {
 	p["priority"] = policy.Priority
 	p["apply_to"] = policy.ApplyTo
 
-	policyDefinition := make(map[string]interface{})
-	for key, value := range policy.Definition {
-		switch v := value.(type) {
-		case float64:
-			value = strconv.FormatFloat(v, 'f', -1, 64)
-		case []interface{}:
-			var nodes []string
-			for _, node := range v {
-				if n, ok := node.(string); ok {
-					nodes = append(nodes, n)
-				}
-			}
-			value = strings.Join(nodes, ",")
+	// The definition is read back in the form used in the configuration.
+	if v, ok := d.GetOk("policy.0.definition_json"); ok && v.(string) != "" {
+		bytes, err := json.Marshal(policy.Definition)
+		if err != nil {
+			return fmt.Errorf("could not encode definition as JSON: %w", err)
 		}
-		policyDefinition[key] = value
+		p["definition_json"] = string(bytes)
+	} else {
+		p["definition"] = policyDefinitionToMap(policy.Definition)
 	}
-	p["definition"] = policyDefinition
 	setPolicy[0] = p
 
 	d.Set("policy", setPolicy)
@@ -140,16 +161,13 @@ func ReadPolicy(d *schema.ResourceData, meta interface{}) error {
 }
 
 func UpdatePolicy(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
-	policyId := strings.Split(d.Id(), "@")
-	if len(policyId) < 2 {
-		return fmt.Errorf("Unable to determine policy ID")
+	name, vhost, err := parseResourceId(d)
+	if err != nil {
+		return err
 	}
 
-	name := policyId[0]
-	vhost := policyId[1]
-
 	if d.HasChange("policy") {
 		_, newPolicy := d.GetChange("policy")
 
@@ -167,16 +185,58 @@ func UpdatePolicy(d *schema.ResourceData, meta interface{}) error {
 	return ReadPolicy(d, meta)
 }
 
-func DeletePolicy(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+// CustomizeDiffPolicy makes sure the policy has a definition, checks its
+// keys against the catalogue of known policy keys and, when overlap_check is
+// set, looks for other policies with the same priority
+func CustomizeDiffPolicy(d *schema.ResourceDiff, meta interface{}) error {
+	for _, key := range []string{"name", "vhost", "policy.0.pattern", "policy.0.priority", "policy.0.apply_to", "policy.0.definition", "policy.0.definition_json"} {
+		if !d.NewValueKnown(key) {
+			return nil
+		}
+	}
 
-	policyId := strings.Split(d.Id(), "@")
-	if len(policyId) < 2 {
-		return fmt.Errorf("Unable to determine policy ID")
+	definition, hasDefinition := d.GetOk("policy.0.definition")
+	definitionJson, hasDefinitionJson := d.GetOk("policy.0.definition_json")
+	if !hasDefinition && !hasDefinitionJson {
+		return fmt.Errorf("One of policy.0.definition or policy.0.definition_json must be set")
 	}
 
-	name := policyId[0]
-	vhost := policyId[1]
+	rmqc := meta.(*rabbitmqClient)
+	applyTo := d.Get("policy.0.apply_to").(string)
+
+	if hasDefinitionJson {
+		parsed, err := PolicyDefinition(map[string]interface{}{"definition_json": definitionJson})
+		if err != nil {
+			return err
+		}
+		if err := validatePolicyDefinition(rmqc, applyTo, parsed); err != nil {
+			return err
+		}
+	} else if err := validatePolicyDefinition(rmqc, applyTo, definition.(map[string]interface{})); err != nil {
+		return err
+	}
+
+	mode := d.Get("overlap_check").(string)
+	if mode == "" {
+		return nil
+	}
+
+	return checkPolicyOverlaps(rmqc, rabbithole.Policy{
+		Name:     d.Get("name").(string),
+		Vhost:    d.Get("vhost").(string),
+		Pattern:  d.Get("policy.0.pattern").(string),
+		Priority: d.Get("policy.0.priority").(int),
+		ApplyTo:  applyTo,
+	}, mode)
+}
+
+func DeletePolicy(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	name, vhost, err := parseResourceId(d)
+	if err != nil {
+		return err
+	}
 
 	log.Printf("[DEBUG] RabbitMQ: Attempting to delete policy for %s", d.Id())
 
@@ -198,7 +258,99 @@ func DeletePolicy(d *schema.ResourceData, meta interface{}) error {
 	return nil
 }
 
-func putPolicy(rmqc *rabbithole.Client, vhost string, name string, policyMap map[string]interface{}) error {
+// PolicyDefinition returns the definition of a policy block, as it is
+// declared in RabbitMQ. definition_json is used as it is, while the values of
+// definition, which are all strings, are converted to the types expected by
+// RabbitMQ.
+func PolicyDefinition(policyMap map[string]interface{}) (rabbithole.PolicyDefinition, error) {
+	if v, ok := policyMap["definition_json"].(string); ok && v != "" {
+		// Numbers are kept as they are written, so that no precision is lost.
+		decoder := json.NewDecoder(strings.NewReader(v))
+		decoder.UseNumber()
+
+		var definition map[string]interface{}
+		if err := decoder.Decode(&definition); err != nil {
+			return nil, fmt.Errorf("Unable to parse definition_json: %s", err)
+		}
+
+		return definition, nil
+	}
+
+	definition := rabbithole.PolicyDefinition{}
+	if v, ok := policyMap["definition"].(map[string]interface{}); ok {
+		for key, val := range v {
+			definition[key] = val
+		}
+
+		// special case for ha-mode = nodes
+		if x, ok := definition["ha-mode"]; ok && x == "nodes" {
+			if params, ok := definition["ha-params"].(string); ok {
+				definition["ha-params"] = rabbithole.NodeNames(strings.Split(params, ","))
+			}
+		}
+
+		// special case for integers
+		for key, val := range definition {
+			if x, ok := val.(string); ok {
+				if x, err := strconv.ParseInt(x, 10, 64); err == nil {
+					definition[key] = x
+				}
+			}
+		}
+	}
+
+	return definition, nil
+}
+
+// policyDefinitionToMap converts a definition read from RabbitMQ to the
+// string values of the definition attribute.
+func policyDefinitionToMap(definition rabbithole.PolicyDefinition) map[string]interface{} {
+	policyDefinition := make(map[string]interface{})
+	for key, value := range definition {
+		switch v := value.(type) {
+		case json.Number:
+			value = v.String()
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			value = strconv.FormatBool(v)
+		case []interface{}:
+			var nodes []string
+			for _, node := range v {
+				if n, ok := node.(string); ok {
+					nodes = append(nodes, n)
+				}
+			}
+			value = strings.Join(nodes, ",")
+		}
+		policyDefinition[key] = value
+	}
+
+	return policyDefinition
+}
+
+// getPolicy reads a policy like GetPolicy does, but keeps the numbers of the
+// definition as json.Number, since decoding them as float64 changes integers
+// above 2^53.
+func getPolicy(rmqc *rabbitmqClient, vhost string, name string) (*rabbithole.Policy, error) {
+	resp, err := rmqc.executeRequest("GET", "policies/"+url.PathEscape(vhost)+"/"+url.PathEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+
+	var policy rabbithole.Policy
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+func putPolicy(rmqc *rabbitmqClient, vhost string, name string, policyMap map[string]interface{}) error {
 	policy := rabbithole.Policy{}
 	policy.Vhost = vhost
 	policy.Name = name
@@ -215,29 +367,11 @@ func putPolicy(rmqc *rabbithole.Client, vhost string, name string, policyMap map
 		policy.ApplyTo = v
 	}
 
-	if v, ok := policyMap["definition"].(map[string]interface{}); ok {
-		// special case for ha-mode = nodes
-		if x, ok := v["ha-mode"]; ok && x == "nodes" {
-			var nodes rabbithole.NodeNames
-			if _, ok := v["ha-params"].(string); ok {
-				nodes = strings.Split(v["ha-params"].(string), ",")
-				v["ha-params"] = nodes
-			}
-		}
-
-		// special case for integers
-		for key, val := range v {
-			if x, ok := val.(string); ok {
-				if x, err := strconv.ParseInt(x, 10, 64); err == nil {
-					v[key] = x
-				}
-			}
-		}
-
-		policyDefinition := rabbithole.PolicyDefinition{}
-		policyDefinition = v
-		policy.Definition = policyDefinition
+	definition, err := PolicyDefinition(policyMap)
+	if err != nil {
+		return err
 	}
+	policy.Definition = definition
 
 	log.Printf("[DEBUG] RabbitMQ: Attempting to declare policy for %s@%s: %#v", name, vhost, policy)
 
@@ -253,3 +387,84 @@ func putPolicy(rmqc *rabbithole.Client, vhost string, name string, policyMap map
 
 	return nil
 }
+
+// policyTargetInfo holds the policy related attributes of queues and
+// exchanges, which rabbit-hole doesn't all expose.
+type policyTargetInfo struct {
+	Name  string `json:"name"`
+	Vhost string `json:"vhost"`
+	// Type of the queue, e.g. quorum, or of the exchange, e.g. topic
+	Type   string `json:"type"`
+	Policy string `json:"policy"`
+	// Only reported for queues, as an empty list when no policy applies
+	EffectivePolicyDefinition interface{} `json:"effective_policy_definition"`
+}
+
+// listPolicyTargets lists the queues or exchanges of a vhost, kind being
+// either "queues" or "exchanges".
+func listPolicyTargets(rmqc *rabbitmqClient, kind string, vhost string) ([]policyTargetInfo, error) {
+	var rec []policyTargetInfo
+	err := rmqc.executeAndParseRequest(kind+"/"+url.PathEscape(vhost), &rec)
+	return rec, err
+}
+
+// getPolicyTarget reads a queue or an exchange, kind being either "queues" or
+// "exchanges". The response is decoded into rec, the rabbit-hole type of the
+// object, and into the returned policy attributes, which rabbit-hole doesn't
+// expose, so that a single request is sent.
+func getPolicyTarget(rmqc *rabbitmqClient, kind string, vhost string, name string, rec interface{}) (*policyTargetInfo, error) {
+	resp, err := rmqc.executeRequest("GET", kind+"/"+url.PathEscape(vhost)+"/"+url.PathEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(body, rec); err != nil {
+		return nil, err
+	}
+
+	var info policyTargetInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// setEffectivePolicy sets the effective_policy and effective_policy_definition
+// attributes of a queue or an exchange from its policy attributes.
+func setEffectivePolicy(rmqc *rabbitmqClient, d *schema.ResourceData, info *policyTargetInfo) error {
+	// Exchanges don't report the definition, which is then read from the
+	// policy. A policy deleted in the meantime no longer applies.
+	definition, ok := info.EffectivePolicyDefinition.(map[string]interface{})
+	if !ok && info.Policy != "" {
+		policy, err := getPolicy(rmqc, info.Vhost, info.Policy)
+		if err != nil {
+			if rmqErr, ok := err.(rabbithole.ErrorResponse); !ok || rmqErr.StatusCode != 404 {
+				return err
+			}
+			info.Policy = ""
+		} else {
+			definition = policy.Definition
+		}
+	}
+
+	effectiveDefinition := ""
+	if info.Policy != "" {
+		bytes, err := json.Marshal(definition)
+		if err != nil {
+			return fmt.Errorf("could not encode effective policy definition as JSON: %w", err)
+		}
+		effectiveDefinition = string(bytes)
+	}
+
+	d.Set("effective_policy", info.Policy)
+	d.Set("effective_policy_definition", effectiveDefinition)
+
+	return nil
+}
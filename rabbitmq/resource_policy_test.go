@@ -1,8 +1,10 @@
 package rabbitmq
 
 import (
+	"encoding/json"
 	"fmt"
-	"strings"
+	"reflect"
+	"regexp"
 	"testing"
 
 	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
@@ -34,6 +36,183 @@ func TestAccPolicy(t *testing.T) {
 	})
 }
 
+func TestAccPolicy_definitionJson(t *testing.T) {
+	var policy rabbithole.Policy
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccPolicyCheckDestroy(&policy),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccPolicyConfig_definitionJson,
+				Check: resource.ComposeTestCheckFunc(
+					testAccPolicyCheck("rabbitmq_policy.test", &policy),
+					testAccPolicyCheckDefinition("rabbitmq_policy.test", "federation-upstream-set", "1234"),
+				),
+			},
+			{
+				Config:   testAccPolicyConfig_definitionJson,
+				PlanOnly: true,
+			},
+			{
+				Config: testAccPolicyConfig_basic,
+				Check: testAccPolicyCheck(
+					"rabbitmq_policy.test", &policy,
+				),
+			},
+		},
+	})
+}
+
+func TestAccPolicy_matches(t *testing.T) {
+	var policy rabbithole.Policy
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccPolicyCheckDestroy(&policy),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccPolicyConfig_matches,
+				Check: resource.ComposeTestCheckFunc(
+					testAccPolicyCheck("rabbitmq_policy.test", &policy),
+					resource.TestCheckResourceAttr("data.rabbitmq_policy_matches.test", "queues.#", "1"),
+					resource.TestCheckResourceAttr("data.rabbitmq_policy_matches.test", "queues.0", "orders.created"),
+					resource.TestCheckResourceAttr("data.rabbitmq_policy_matches.test", "exchanges.#", "0"),
+					resource.TestCheckResourceAttr("data.rabbitmq_policy_matches.test", "effective_queues.#", "1"),
+					resource.TestCheckResourceAttr("data.rabbitmq_policy_matches.test", "effective_queues.0", "orders.created"),
+				),
+			},
+			{
+				// The queue is read again once the policy exists.
+				Config: testAccPolicyConfig_matches,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("rabbitmq_queue.orders", "effective_policy", "test"),
+					resource.TestCheckResourceAttr("rabbitmq_queue.other", "effective_policy", ""),
+					resource.TestCheckResourceAttr("rabbitmq_exchange.orders", "effective_policy", ""),
+				),
+			},
+		},
+	})
+}
+
+func TestAccPolicy_overlapCheck(t *testing.T) {
+	var policy rabbithole.Policy
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccPolicyCheckDestroy(&policy),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccPolicyConfig_overlapCheck(""),
+				Check: testAccPolicyCheck(
+					"rabbitmq_policy.test", &policy,
+				),
+			},
+			{
+				Config:      testAccPolicyConfig_overlapCheck(testAccPolicyConfig_overlapping(0)),
+				ExpectError: regexp.MustCompile(`overlaps with other policies of the same priority:\s+policy test \(priority 0\) on queue "orders.created"`),
+			},
+			{
+				Config: testAccPolicyConfig_overlapCheck(testAccPolicyConfig_overlapping(1)),
+				Check: testAccPolicyCheck(
+					"rabbitmq_policy.orders", &policy,
+				),
+			},
+		},
+	})
+}
+
+func TestPolicyDefinition(t *testing.T) {
+	definition, err := PolicyDefinition(map[string]interface{}{
+		"definition": map[string]interface{}{
+			"ha-mode":    "nodes",
+			"ha-params":  "a,b",
+			"max-length": "10000",
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := rabbithole.PolicyDefinition{
+		"ha-mode":    "nodes",
+		"ha-params":  rabbithole.NodeNames{"a", "b"},
+		"max-length": int64(10000),
+	}
+	if !reflect.DeepEqual(definition, expected) {
+		t.Errorf("Expected definition %#v, got %#v", expected, definition)
+	}
+
+	definition, err = PolicyDefinition(map[string]interface{}{
+		"definition_json": `{"federation-upstream-set": "1234", "max-length-bytes": 12345678901234567890, "ha-params": ["a"]}`,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected = rabbithole.PolicyDefinition{
+		"federation-upstream-set": "1234",
+		"max-length-bytes":        json.Number("12345678901234567890"),
+		"ha-params":               []interface{}{"a"},
+	}
+	if !reflect.DeepEqual(definition, expected) {
+		t.Errorf("Expected definition %#v, got %#v", expected, definition)
+	}
+
+	if _, err := PolicyDefinition(map[string]interface{}{"definition_json": `[]`}); err == nil {
+		t.Errorf("PolicyDefinition should reject definitions that are not objects")
+	}
+}
+
+func TestPolicyDefinitionToMap(t *testing.T) {
+	definition := policyDefinitionToMap(rabbithole.PolicyDefinition{
+		"ha-mode":                "nodes",
+		"ha-params":              []interface{}{"a", "b"},
+		"max-length":             float64(10000),
+		"max-length-bytes":       json.Number("9007199254740993"),
+		"ha-promote-on-shutdown": "always",
+		"single-active-consumer": true,
+	})
+
+	expected := map[string]interface{}{
+		"ha-mode":                "nodes",
+		"ha-params":              "a,b",
+		"max-length":             "10000",
+		"max-length-bytes":       "9007199254740993",
+		"ha-promote-on-shutdown": "always",
+		"single-active-consumer": "true",
+	}
+	if !reflect.DeepEqual(definition, expected) {
+		t.Errorf("Expected definition %#v, got %#v", expected, definition)
+	}
+}
+
+func testAccPolicyCheckDefinition(rn, key, value string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[rn]
+		if !ok {
+			return fmt.Errorf("resource not found: %s", rn)
+		}
+
+		name, vhost, err := parseId(rs.Primary.ID)
+		if err != nil {
+			return err
+		}
+
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
+		policy, err := rmqc.GetPolicy(vhost, name)
+		if err != nil {
+			return fmt.Errorf("Error retrieving policy: %s", err)
+		}
+
+		if policy.Definition[key] != value {
+			return fmt.Errorf("Expected %s to be %q, got %#v", key, value, policy.Definition[key])
+		}
+
+		return nil
+	}
+}
+
 func testAccPolicyCheck(rn string, policy *rabbithole.Policy) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[rn]
@@ -45,8 +224,11 @@ func testAccPolicyCheck(rn string, policy *rabbithole.Policy) resource.TestCheck
 			return fmt.Errorf("policy id not set")
 		}
 
-		rmqc := testAccProvider.Meta().(*rabbithole.Client)
-		policyParts := strings.Split(rs.Primary.ID, "@")
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
+		name, vhost, err := parseId(rs.Primary.ID)
+		if err != nil {
+			return err
+		}
 
 		policies, err := rmqc.ListPolicies()
 		if err != nil {
@@ -54,7 +236,7 @@ func testAccPolicyCheck(rn string, policy *rabbithole.Policy) resource.TestCheck
 		}
 
 		for _, p := range policies {
-			if p.Name == policyParts[0] && p.Vhost == policyParts[1] {
+			if p.Name == name && p.Vhost == vhost {
 				policy = &p
 				return nil
 			}
@@ -66,7 +248,7 @@ func testAccPolicyCheck(rn string, policy *rabbithole.Policy) resource.TestCheck
 
 func testAccPolicyCheckDestroy(policy *rabbithole.Policy) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
-		rmqc := testAccProvider.Meta().(*rabbithole.Client)
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
 
 		policies, err := rmqc.ListPolicies()
 		if err != nil {
@@ -140,3 +322,155 @@ resource "rabbitmq_policy" "test" {
         }
     }
 }`
+
+const testAccPolicyConfig_definitionJson = `
+resource "rabbitmq_vhost" "test" {
+    name = "test"
+}
+
+resource "rabbitmq_permissions" "guest" {
+    user = "guest"
+    vhost = "${rabbitmq_vhost.test.name}"
+    permissions {
+        configure = ".*"
+        write = ".*"
+        read = ".*"
+    }
+}
+
+resource "rabbitmq_policy" "test" {
+    name = "test"
+    vhost = "${rabbitmq_permissions.guest.vhost}"
+    policy {
+        pattern = ".*"
+        priority = 0
+        apply_to = "all"
+        definition_json = <<EOF
+{
+  "federation-upstream-set": "1234",
+  "max-length": 10000,
+  "max-length-bytes": 9007199254740993
+}
+EOF
+    }
+}`
+
+const testAccPolicyConfig_matches = `
+resource "rabbitmq_vhost" "test" {
+    name = "test"
+}
+
+resource "rabbitmq_permissions" "guest" {
+    user = "guest"
+    vhost = "${rabbitmq_vhost.test.name}"
+    permissions {
+        configure = ".*"
+        write = ".*"
+        read = ".*"
+    }
+}
+
+resource "rabbitmq_queue" "orders" {
+    name = "orders.created"
+    vhost = "${rabbitmq_permissions.guest.vhost}"
+    settings {
+        durable = false
+        auto_delete = true
+    }
+}
+
+resource "rabbitmq_queue" "other" {
+    name = "invoices.created"
+    vhost = "${rabbitmq_permissions.guest.vhost}"
+    settings {
+        durable = false
+        auto_delete = true
+    }
+}
+
+resource "rabbitmq_exchange" "orders" {
+    name = "orders.events"
+    vhost = "${rabbitmq_permissions.guest.vhost}"
+    settings {
+        type = "fanout"
+        durable = false
+        auto_delete = true
+    }
+}
+
+resource "rabbitmq_policy" "test" {
+    name = "test"
+    vhost = "${rabbitmq_permissions.guest.vhost}"
+    policy {
+        pattern = "^orders\\."
+        priority = 0
+        apply_to = "queues"
+        definition = {
+            max-length = 10000
+        }
+    }
+}
+
+data "rabbitmq_policy_matches" "test" {
+    vhost = "${rabbitmq_policy.test.vhost}"
+    policy = "${rabbitmq_policy.test.name}"
+
+    depends_on = ["rabbitmq_queue.orders", "rabbitmq_queue.other", "rabbitmq_exchange.orders"]
+}`
+
+func testAccPolicyConfig_overlapCheck(extra string) string {
+	return `
+resource "rabbitmq_vhost" "test" {
+    name = "test"
+}
+
+resource "rabbitmq_permissions" "guest" {
+    user = "guest"
+    vhost = "${rabbitmq_vhost.test.name}"
+    permissions {
+        configure = ".*"
+        write = ".*"
+        read = ".*"
+    }
+}
+
+resource "rabbitmq_queue" "orders" {
+    name = "orders.created"
+    vhost = "${rabbitmq_permissions.guest.vhost}"
+    settings {
+        durable = false
+        auto_delete = false
+    }
+}
+
+resource "rabbitmq_policy" "test" {
+    name = "test"
+    vhost = "${rabbitmq_permissions.guest.vhost}"
+    policy {
+        pattern = ".*"
+        priority = 0
+        apply_to = "all"
+        definition = {
+            max-length = 10000
+        }
+    }
+}
+` + extra
+}
+
+func testAccPolicyConfig_overlapping(priority int) string {
+	return fmt.Sprintf(`
+resource "rabbitmq_policy" "orders" {
+    name = "orders"
+    vhost = "${rabbitmq_permissions.guest.vhost}"
+    overlap_check = "error"
+    policy {
+        pattern = "^orders\\."
+        priority = %d
+        apply_to = "queues"
+        definition = {
+            max-length = 100
+        }
+    }
+}`, priority)
+}
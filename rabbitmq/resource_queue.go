@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"strings"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/structure"
@@ -13,14 +12,16 @@ import (
 )
 
 func resourceQueue() *schema.Resource {
-	return &schema.Resource{
+	r := &schema.Resource{
 		Create: CreateQueue,
 		Read:   ReadQueue,
 		Delete: DeleteQueue,
 		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+			State: importResourceId,
 		},
 
+		SchemaVersion: 1,
+
 		Schema: map[string]*schema.Schema{
 			"name": {
 				Type:     schema.TypeString,
@@ -74,12 +75,28 @@ func resourceQueue() *schema.Resource {
 					},
 				},
 			},
+
+			"effective_policy": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"effective_policy_definition": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
 		},
 	}
+
+	r.StateUpgraders = []schema.StateUpgrader{
+		resourceIdStateUpgraderV0(r, "name"),
+	}
+
+	return r
 }
 
 func CreateQueue(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
 	name := d.Get("name").(string)
 	vhost := d.Get("vhost").(string)
@@ -107,24 +124,22 @@ func CreateQueue(d *schema.ResourceData, meta interface{}) error {
 		return err
 	}
 
-	id := fmt.Sprintf("%s@%s", name, vhost)
+	id := ResourceId(name, vhost)
 	d.SetId(id)
 
 	return ReadQueue(d, meta)
 }
 
 func ReadQueue(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
-	queueId := strings.Split(d.Id(), "@")
-	if len(queueId) < 2 {
-		return fmt.Errorf("Unable to determine Queue ID")
+	user, vhost, err := parseResourceId(d)
+	if err != nil {
+		return err
 	}
 
-	user := queueId[0]
-	vhost := queueId[1]
-
-	queueSettings, err := rmqc.GetQueue(vhost, user)
+	queueSettings := &rabbithole.DetailedQueueInfo{}
+	policyInfo, err := getPolicyTarget(rmqc, "queues", vhost, user, queueSettings)
 	if err != nil {
 		return checkDeleted(d, err)
 	}
@@ -159,20 +174,21 @@ func ReadQueue(d *schema.ResourceData, meta interface{}) error {
 	queue := make([]map[string]interface{}, 1)
 	queue[0] = e
 
-	return d.Set("settings", queue)
+	if err := d.Set("settings", queue); err != nil {
+		return err
+	}
+
+	return setEffectivePolicy(rmqc, d, policyInfo)
 }
 
 func DeleteQueue(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
-	queueId := strings.Split(d.Id(), "@")
-	if len(queueId) < 2 {
-		return fmt.Errorf("Unable to determine Queue ID")
+	user, vhost, err := parseResourceId(d)
+	if err != nil {
+		return err
 	}
 
-	user := queueId[0]
-	vhost := queueId[1]
-
 	log.Printf("[DEBUG] RabbitMQ: Attempting to delete queue for %s", d.Id())
 
 	resp, err := rmqc.DeleteQueue(vhost, user)
@@ -193,7 +209,7 @@ func DeleteQueue(d *schema.ResourceData, meta interface{}) error {
 	return nil
 }
 
-func declareQueue(rmqc *rabbithole.Client, vhost string, name string, settingsMap map[string]interface{}) error {
+func declareQueue(rmqc *rabbitmqClient, vhost string, name string, settingsMap map[string]interface{}) error {
 	queueSettings := rabbithole.QueueSettings{}
 
 	if v, ok := settingsMap["durable"].(bool); ok {
@@ -0,0 +1,144 @@
+package rabbitmq
+
+import (
+	"log"
+
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+// resourceQueueBindings owns every binding whose destination is the queue:
+// bindings missing from the configuration are deleted, except for the
+// implicit binding to the default exchange.
+func resourceQueueBindings() *schema.Resource {
+	return &schema.Resource{
+		Create: CreateQueueBindings,
+		Update: UpdateQueueBindings,
+		Read:   ReadQueueBindings,
+		Delete: DeleteQueueBindings,
+		Importer: &schema.ResourceImporter{
+			State: importResourceId,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"destination": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+
+			"vhost": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+
+			"binding": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem: bindingSetElem(map[string]*schema.Schema{
+					"source": {
+						Type:     schema.TypeString,
+						Required: true,
+					},
+				}),
+			},
+		},
+	}
+}
+
+func CreateQueueBindings(d *schema.ResourceData, meta interface{}) error {
+	destination := d.Get("destination").(string)
+	vhost := d.Get("vhost").(string)
+
+	if err := putQueueBindings(d, meta, destination, vhost); err != nil {
+		return err
+	}
+
+	d.SetId(ResourceId(destination, vhost))
+
+	return ReadQueueBindings(d, meta)
+}
+
+func ReadQueueBindings(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	destination, vhost, err := parseResourceId(d)
+	if err != nil {
+		return err
+	}
+
+	bindings, err := rmqc.ListQueueBindings(vhost, destination)
+	if err != nil {
+		return checkDeleted(d, err)
+	}
+
+	log.Printf("[DEBUG] RabbitMQ: Bindings retrieved for queue %s: %#v", d.Id(), bindings)
+
+	d.Set("destination", destination)
+	d.Set("vhost", vhost)
+
+	set := make([]map[string]interface{}, 0, len(bindings))
+	for _, binding := range bindings {
+		if bindingIgnored(binding) {
+			continue
+		}
+		set = append(set, map[string]interface{}{
+			"source":      binding.Source,
+			"routing_key": binding.RoutingKey,
+			"arguments":   bindingArgumentsToMap(binding.Arguments),
+		})
+	}
+
+	return d.Set("binding", set)
+}
+
+func UpdateQueueBindings(d *schema.ResourceData, meta interface{}) error {
+	destination, vhost, err := parseResourceId(d)
+	if err != nil {
+		return err
+	}
+
+	if err := putQueueBindings(d, meta, destination, vhost); err != nil {
+		return err
+	}
+
+	return ReadQueueBindings(d, meta)
+}
+
+func DeleteQueueBindings(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	destination, vhost, err := parseResourceId(d)
+	if err != nil {
+		return err
+	}
+
+	actual, err := rmqc.ListQueueBindings(vhost, destination)
+	if err != nil {
+		return checkDeleted(d, err)
+	}
+
+	// Only the bindings of the configuration are deleted.
+	managed := bindingsFromSet(d.Get("binding").(*schema.Set), queueBindingTemplate(destination))
+
+	return deleteBindings(rmqc, vhost, managed, actual)
+}
+
+func putQueueBindings(d *schema.ResourceData, meta interface{}, destination, vhost string) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	actual, err := rmqc.ListQueueBindings(vhost, destination)
+	if err != nil {
+		return err
+	}
+
+	desired := bindingsFromSet(d.Get("binding").(*schema.Set), queueBindingTemplate(destination))
+
+	return reconcileBindings(rmqc, vhost, desired, actual)
+}
+
+func queueBindingTemplate(destination string) rabbithole.BindingInfo {
+	return rabbithole.BindingInfo{Destination: destination, DestinationType: "queue"}
+}
@@ -0,0 +1,124 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"testing"
+
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+func TestAccQueueBindings_basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccQueueBindingsCheckDestroy("a", "test"),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccQueueBindingsConfig_basic,
+				Check: testAccQueueBindingsCheck(
+					"rabbitmq_queue_bindings.test", "orders.#",
+				),
+			},
+			{
+				// A binding declared outside of Terraform is removed, while
+				// the implicit binding to the default exchange is kept.
+				PreConfig: func() {
+					rmqc := testAccProvider.Meta().(*rabbitmqClient)
+					if _, err := declareBinding(rmqc, "test", rabbithole.BindingInfo{
+						Source:          "test",
+						Destination:     "a",
+						DestinationType: "queue",
+						RoutingKey:      "stray",
+					}); err != nil {
+						t.Fatal(err)
+					}
+				},
+				Config: testAccQueueBindingsConfig_basic,
+				Check: resource.ComposeTestCheckFunc(
+					testAccQueueBindingsCheck("rabbitmq_queue_bindings.test", "orders.#"),
+					testAccQueueBindingsCheckDefaultExchange("a", "test"),
+				),
+			},
+		},
+	})
+}
+
+func testAccQueueBindingsCheck(rn string, routingKeys ...string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[rn]
+		if !ok {
+			return fmt.Errorf("resource not found: %s", rn)
+		}
+
+		if rs.Primary.ID == "" {
+			return fmt.Errorf("queue bindings id not set")
+		}
+
+		destination, vhost, err := parseId(rs.Primary.ID)
+		if err != nil {
+			return err
+		}
+
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
+		bindings, err := rmqc.ListQueueBindings(vhost, destination)
+		if err != nil {
+			return fmt.Errorf("Error retrieving bindings: %s", err)
+		}
+
+		return testAccCheckBindingRoutingKeys(bindings, routingKeys)
+	}
+}
+
+func testAccQueueBindingsCheckDefaultExchange(queue, vhost string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
+		bindings, err := rmqc.ListQueueBindings(vhost, queue)
+		if err != nil {
+			return fmt.Errorf("Error retrieving bindings: %s", err)
+		}
+
+		for _, binding := range bindings {
+			if bindingIgnored(binding) {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("Binding to the default exchange was deleted")
+	}
+}
+
+func testAccQueueBindingsCheckDestroy(queue, vhost string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
+
+		bindings, err := rmqc.ListQueueBindings(vhost, queue)
+		if err != nil {
+			if rmqErr, ok := err.(rabbithole.ErrorResponse); ok && rmqErr.StatusCode == 404 {
+				return nil
+			}
+			return fmt.Errorf("Error retrieving bindings: %s", err)
+		}
+
+		for _, binding := range bindings {
+			if !bindingIgnored(binding) {
+				return fmt.Errorf("Binding still exists: %#v", binding)
+			}
+		}
+
+		return nil
+	}
+}
+
+const testAccQueueBindingsConfig_basic = testAccExchangeBindingsConfig_base + `
+resource "rabbitmq_queue_bindings" "test" {
+    destination = "${rabbitmq_queue.a.name}"
+    vhost = "${rabbitmq_vhost.test.name}"
+
+    binding {
+        source = "${rabbitmq_exchange.test.name}"
+        routing_key = "orders.#"
+    }
+}`
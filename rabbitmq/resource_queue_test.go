@@ -54,6 +54,30 @@ func TestAccQueue_jsonArguments(t *testing.T) {
 	})
 }
 
+func TestAccQueue_atSign(t *testing.T) {
+	var queueInfo rabbithole.QueueInfo
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccQueueCheckDestroy(&queueInfo),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccQueueConfig_atSign,
+				Check: resource.ComposeTestCheckFunc(
+					testAccQueueCheck("rabbitmq_queue.test", &queueInfo),
+					resource.TestCheckResourceAttr("rabbitmq_queue.test", "id", "orders%40eu@test"),
+				),
+			},
+			{
+				ResourceName:      "rabbitmq_queue.test",
+				ImportState:       true,
+				ImportStateId:     "orders@eu@test",
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
 func testAccQueueCheck(rn string, queueInfo *rabbithole.QueueInfo) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[rn]
@@ -65,16 +89,19 @@ func testAccQueueCheck(rn string, queueInfo *rabbithole.QueueInfo) resource.Test
 			return fmt.Errorf("queue id not set")
 		}
 
-		rmqc := testAccProvider.Meta().(*rabbithole.Client)
-		queueParts := strings.Split(rs.Primary.ID, "@")
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
+		name, vhost, err := parseId(rs.Primary.ID)
+		if err != nil {
+			return err
+		}
 
-		queues, err := rmqc.ListQueuesIn(queueParts[1])
+		queues, err := rmqc.ListQueuesIn(vhost)
 		if err != nil {
 			return fmt.Errorf("Error retrieving queue: %s", err)
 		}
 
 		for _, queue := range queues {
-			if queue.Name == queueParts[0] && queue.Vhost == queueParts[1] {
+			if queue.Name == name && queue.Vhost == vhost {
 				*queueInfo = queue
 				return nil
 			}
@@ -112,7 +139,7 @@ func testAccQueueCheckJsonArguments(rn string, queueInfo *rabbithole.QueueInfo,
 
 func testAccQueueCheckDestroy(queueInfo *rabbithole.QueueInfo) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
-		rmqc := testAccProvider.Meta().(*rabbithole.Client)
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
 
 		queues, err := rmqc.ListQueuesIn(queueInfo.Vhost)
 		if err != nil && !strings.Contains(strings.ToLower(err.Error()), "not found") {
@@ -153,6 +180,30 @@ resource "rabbitmq_queue" "test" {
     }
 }`
 
+const testAccQueueConfig_atSign = `
+resource "rabbitmq_vhost" "test" {
+    name = "test"
+}
+
+resource "rabbitmq_permissions" "guest" {
+    user = "guest"
+    vhost = "${rabbitmq_vhost.test.name}"
+    permissions {
+        configure = ".*"
+        write = ".*"
+        read = ".*"
+    }
+}
+
+resource "rabbitmq_queue" "test" {
+    name = "orders@eu"
+    vhost = "${rabbitmq_permissions.guest.vhost}"
+    settings {
+        durable = false
+        auto_delete = true
+    }
+}`
+
 const testAccQueueConfig_update = `
 resource "rabbitmq_vhost" "test" {
     name = "test"
@@ -0,0 +1,490 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+)
+
+func resourceServiceAccount() *schema.Resource {
+	s := userSchema()
+
+	s["permissions"] = userPermissionsSchema()
+
+	s["topic_permissions"] = &schema.Schema{
+		Type:     schema.TypeSet,
+		Optional: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"vhost": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+
+				"exchange": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+
+				"write": {
+					Type:         schema.TypeString,
+					Required:     true,
+					ValidateFunc: validatePermissionRegexp(true),
+				},
+
+				"read": {
+					Type:         schema.TypeString,
+					Required:     true,
+					ValidateFunc: validatePermissionRegexp(true),
+				},
+			},
+		},
+	}
+
+	s["limits"] = &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		MaxItems: 1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"max_connections": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					ValidateFunc: validation.IntAtLeast(1),
+				},
+
+				"max_channels": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					ValidateFunc: validation.IntAtLeast(1),
+				},
+			},
+		},
+	}
+
+	return &schema.Resource{
+		Create:        CreateServiceAccount,
+		Update:        UpdateServiceAccount,
+		Read:          ReadServiceAccount,
+		Delete:        DeleteServiceAccount,
+		CustomizeDiff: CustomizeDiffServiceAccount,
+		Importer: &schema.ResourceImporter{
+			State: importServiceAccount,
+		},
+
+		Schema: s,
+	}
+}
+
+// userPermissionsSchema returns the schema of the permissions of a user, one
+// block per vhost.
+func userPermissionsSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeSet,
+		Optional: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"vhost": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+
+				"configure": {
+					Type:         schema.TypeString,
+					Required:     true,
+					ValidateFunc: validatePermissionRegexp(false),
+				},
+
+				"write": {
+					Type:         schema.TypeString,
+					Required:     true,
+					ValidateFunc: validatePermissionRegexp(false),
+				},
+
+				"read": {
+					Type:         schema.TypeString,
+					Required:     true,
+					ValidateFunc: validatePermissionRegexp(false),
+				},
+			},
+		},
+	}
+}
+
+// Names of the user limits in the management API, by attribute of the limits block
+var serviceAccountLimits = map[string]string{
+	"max_connections": "max-connections",
+	"max_channels":    "max-channels",
+}
+
+func CreateServiceAccount(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	name := d.Get("name").(string)
+
+	log.Printf("[DEBUG] RabbitMQ: Attempting to create service account %s", name)
+
+	resp, err := putUser(rmqc, name, d)
+	log.Printf("[DEBUG] RabbitMQ: user creation response: %#v", resp)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("Error creating RabbitMQ user: %s", resp.Status)
+	}
+
+	d.SetId(name)
+
+	// The user exists from now on, so a failure below leaves a tainted
+	// resource that is cleaned up by the next apply.
+	for _, perm := range d.Get("permissions").(*schema.Set).List() {
+		permsMap := perm.(map[string]interface{})
+		if err := setPermissionsIn(rmqc, permsMap["vhost"].(string), name, permsMap); err != nil {
+			return err
+		}
+	}
+
+	for _, perm := range d.Get("topic_permissions").(*schema.Set).List() {
+		permsMap := perm.(map[string]interface{})
+		if err := setTopicPermissionsIn(rmqc, permsMap["vhost"].(string), name, permsMap); err != nil {
+			return err
+		}
+	}
+
+	if err := setServiceAccountLimits(rmqc, name, d); err != nil {
+		return err
+	}
+
+	return ReadServiceAccount(d, meta)
+}
+
+func ReadServiceAccount(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	name := d.Id()
+
+	user, err := rmqc.GetUser(name)
+	if err != nil {
+		return checkDeleted(d, err)
+	}
+
+	log.Printf("[DEBUG] RabbitMQ: User retrieved: %s", user.Name)
+
+	setUserData(d, user)
+
+	// Only the grants on the configured vhosts and exchanges are read, so
+	// that the grants of other vhosts can be managed by separate resources.
+	vhosts := map[string]bool{}
+	for _, perm := range d.Get("permissions").(*schema.Set).List() {
+		vhosts[perm.(map[string]interface{})["vhost"].(string)] = true
+	}
+
+	permissions, err := serviceAccountPermissions(rmqc, name, vhosts)
+	if err != nil {
+		return err
+	}
+	d.Set("permissions", permissions)
+
+	if rmqc.version.atLeast(3, 7, 0) {
+		exchanges := map[string]bool{}
+		for _, perm := range d.Get("topic_permissions").(*schema.Set).List() {
+			permsMap := perm.(map[string]interface{})
+			exchanges[permsMap["vhost"].(string)+"/"+permsMap["exchange"].(string)] = true
+		}
+
+		topicPermissions, err := serviceAccountTopicPermissions(rmqc, name, exchanges)
+		if err != nil {
+			return err
+		}
+		d.Set("topic_permissions", topicPermissions)
+	}
+
+	if rmqc.version.atLeast(3, 8, 10) {
+		limits, err := getServiceAccountLimits(rmqc, name)
+		if err != nil {
+			return err
+		}
+		d.Set("limits", limits)
+	}
+
+	return nil
+}
+
+func UpdateServiceAccount(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	name := d.Id()
+
+	log.Printf("[DEBUG] RabbitMQ: Attempting to update service account %s", name)
+
+	resp, err := putUser(rmqc, name, d)
+	log.Printf("[DEBUG] RabbitMQ: User update response: %#v", resp)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("Error updating RabbitMQ user: %s", resp.Status)
+	}
+
+	if d.HasChange("permissions") {
+		o, n := d.GetChange("permissions")
+
+		kept := map[string]bool{}
+		for _, perm := range n.(*schema.Set).List() {
+			permsMap := perm.(map[string]interface{})
+			vhost := permsMap["vhost"].(string)
+			kept[vhost] = true
+			if err := setPermissionsIn(rmqc, vhost, name, permsMap); err != nil {
+				return err
+			}
+		}
+
+		for _, perm := range o.(*schema.Set).List() {
+			vhost := perm.(map[string]interface{})["vhost"].(string)
+			if kept[vhost] {
+				continue
+			}
+			if err := clearPermissionsIn(rmqc, vhost, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if d.HasChange("topic_permissions") {
+		o, n := d.GetChange("topic_permissions")
+
+		kept := map[string]bool{}
+		for _, perm := range n.(*schema.Set).List() {
+			permsMap := perm.(map[string]interface{})
+			vhost := permsMap["vhost"].(string)
+			kept[vhost+"/"+permsMap["exchange"].(string)] = true
+			if err := setTopicPermissionsIn(rmqc, vhost, name, permsMap); err != nil {
+				return err
+			}
+		}
+
+		for _, perm := range o.(*schema.Set).List() {
+			permsMap := perm.(map[string]interface{})
+			vhost := permsMap["vhost"].(string)
+			exchange := permsMap["exchange"].(string)
+			if kept[vhost+"/"+exchange] {
+				continue
+			}
+			if err := deleteTopicPermissionsIn(rmqc, vhost, name, exchange); err != nil {
+				return err
+			}
+		}
+	}
+
+	if d.HasChange("limits") {
+		if err := setServiceAccountLimits(rmqc, name, d); err != nil {
+			return err
+		}
+	}
+
+	return ReadServiceAccount(d, meta)
+}
+
+func DeleteServiceAccount(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	name := d.Id()
+
+	// Grants are revoked before the user is deleted, so that a failure never
+	// leaves permissions behind for a user that could be recreated later.
+	vhosts := map[string]bool{}
+	for _, perm := range d.Get("topic_permissions").(*schema.Set).List() {
+		vhosts[perm.(map[string]interface{})["vhost"].(string)] = true
+	}
+	for vhost := range vhosts {
+		log.Printf("[DEBUG] RabbitMQ: Attempting to delete topic permissions for %s@%s", name, vhost)
+
+		resp, err := rmqc.ClearTopicPermissionsIn(vhost, name)
+		log.Printf("[DEBUG] RabbitMQ: Topic permission delete response: %#v", resp)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode >= 400 && resp.StatusCode != 404 {
+			return fmt.Errorf("Error deleting RabbitMQ topic permission: %s", resp.Status)
+		}
+	}
+
+	for _, perm := range d.Get("permissions").(*schema.Set).List() {
+		if err := clearPermissionsIn(rmqc, perm.(map[string]interface{})["vhost"].(string), name); err != nil {
+			return err
+		}
+	}
+
+	return DeleteUser(d, meta)
+}
+
+// importServiceAccount imports the user with every grant it has, which are
+// then refreshed like configured ones.
+func importServiceAccount(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	rmqc := meta.(*rabbitmqClient)
+
+	permissions, err := serviceAccountPermissions(rmqc, d.Id(), nil)
+	if err != nil {
+		return nil, err
+	}
+	d.Set("permissions", permissions)
+
+	if rmqc.version.atLeast(3, 7, 0) {
+		topicPermissions, err := serviceAccountTopicPermissions(rmqc, d.Id(), nil)
+		if err != nil {
+			return nil, err
+		}
+		d.Set("topic_permissions", topicPermissions)
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// serviceAccountPermissions lists the permissions of a user on the given
+// vhosts, or on every vhost when vhosts is nil.
+func serviceAccountPermissions(rmqc *rabbitmqClient, name string, vhosts map[string]bool) ([]map[string]interface{}, error) {
+	perms, err := rmqc.ListPermissionsOf(name)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make([]map[string]interface{}, 0, len(perms))
+	for _, perm := range perms {
+		if vhosts != nil && !vhosts[perm.Vhost] {
+			continue
+		}
+
+		permissions = append(permissions, map[string]interface{}{
+			"vhost":     perm.Vhost,
+			"configure": perm.Configure,
+			"write":     perm.Write,
+			"read":      perm.Read,
+		})
+	}
+
+	return permissions, nil
+}
+
+// serviceAccountTopicPermissions lists the topic permissions of a user on the
+// given exchanges, as vhost/exchange, or on every exchange when exchanges is
+// nil.
+func serviceAccountTopicPermissions(rmqc *rabbitmqClient, name string, exchanges map[string]bool) ([]map[string]interface{}, error) {
+	perms, err := rmqc.ListTopicPermissionsOf(name)
+	if err != nil {
+		return nil, err
+	}
+
+	topicPermissions := make([]map[string]interface{}, 0, len(perms))
+	for _, perm := range perms {
+		if exchanges != nil && !exchanges[perm.Vhost+"/"+perm.Exchange] {
+			continue
+		}
+
+		topicPermissions = append(topicPermissions, map[string]interface{}{
+			"vhost":    perm.Vhost,
+			"exchange": perm.Exchange,
+			"write":    perm.Write,
+			"read":     perm.Read,
+		})
+	}
+
+	return topicPermissions, nil
+}
+
+// CustomizeDiffServiceAccount checks the user settings and that the broker
+// supports the configured topic permissions and limits
+func CustomizeDiffServiceAccount(d *schema.ResourceDiff, meta interface{}) error {
+	if err := CustomizeDiffUser(d, meta); err != nil {
+		return err
+	}
+
+	rmqc := meta.(*rabbitmqClient)
+
+	if v, ok := d.GetOk("topic_permissions"); ok && v.(*schema.Set).Len() > 0 {
+		if err := rmqc.requireVersion("topic_permissions", 3, 7, 0); err != nil {
+			return err
+		}
+	}
+
+	if v, ok := d.GetOk("limits"); ok && len(v.([]interface{})) > 0 {
+		if err := rmqc.requireVersion("limits", 3, 8, 10); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type userLimitsInfo struct {
+	User  string         `json:"user"`
+	Value map[string]int `json:"value"`
+}
+
+func getServiceAccountLimits(rmqc *rabbitmqClient, name string) ([]map[string]interface{}, error) {
+	var rec []userLimitsInfo
+	if err := rmqc.executeAndParseRequest("user-limits/"+url.PathEscape(name), &rec); err != nil {
+		return nil, err
+	}
+
+	limits := map[string]interface{}{}
+	for _, info := range rec {
+		for attr, key := range serviceAccountLimits {
+			if v, ok := info.Value[key]; ok {
+				limits[attr] = v
+			}
+		}
+	}
+
+	if len(limits) == 0 {
+		return nil, nil
+	}
+
+	return []map[string]interface{}{limits}, nil
+}
+
+// setServiceAccountLimits sets the configured limits and removes the others.
+func setServiceAccountLimits(rmqc *rabbitmqClient, name string, d *schema.ResourceData) error {
+	configured := map[string]interface{}{}
+	if v, ok := d.GetOk("limits"); ok {
+		if l := v.([]interface{}); len(l) > 0 && l[0] != nil {
+			configured = l[0].(map[string]interface{})
+		}
+	}
+
+	if len(configured) == 0 && (d.IsNewResource() || !rmqc.version.atLeast(3, 8, 10)) {
+		return nil
+	}
+
+	for attr, key := range serviceAccountLimits {
+		path := "user-limits/" + url.PathEscape(name) + "/" + key
+
+		// Unset integers read as 0, so a limit only applies when it is set.
+		if _, ok := d.GetOk("limits.0." + attr); ok {
+			value := configured[attr].(int)
+			log.Printf("[DEBUG] RabbitMQ: Attempting to set %s limit of %s to %d", key, name, value)
+
+			resp, err := rmqc.executeRequest("PUT", path, map[string]interface{}{"value": value})
+			if err != nil {
+				return fmt.Errorf("Error setting RabbitMQ user limit %s: %s", key, err)
+			}
+			resp.Body.Close()
+			continue
+		}
+
+		log.Printf("[DEBUG] RabbitMQ: Attempting to clear %s limit of %s", key, name)
+
+		resp, err := rmqc.executeRequest("DELETE", path, nil)
+		if err != nil {
+			return fmt.Errorf("Error clearing RabbitMQ user limit %s: %s", key, err)
+		}
+		resp.Body.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+func TestAccServiceAccount_basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccServiceAccountCheckDestroy("mcservice"),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccServiceAccountConfig_basic,
+				Check: resource.ComposeTestCheckFunc(
+					testAccServiceAccountCheck("rabbitmq_service_account.test", []string{"test"}),
+					testAccUserConnect("mcservice", "foobar"),
+				),
+			},
+			{
+				Config: testAccServiceAccountConfig_update,
+				Check: testAccServiceAccountCheck(
+					"rabbitmq_service_account.test", []string{"test", "test2"},
+				),
+			},
+		},
+	})
+}
+
+func testAccServiceAccountCheck(rn string, vhosts []string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[rn]
+		if !ok {
+			return fmt.Errorf("resource not found: %s", rn)
+		}
+
+		if rs.Primary.ID == "" {
+			return fmt.Errorf("service account id not set")
+		}
+
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
+		if _, err := rmqc.GetUser(rs.Primary.ID); err != nil {
+			return fmt.Errorf("Error retrieving user: %s", err)
+		}
+
+		perms, err := rmqc.ListPermissionsOf(rs.Primary.ID)
+		if err != nil {
+			return fmt.Errorf("Error retrieving permissions: %s", err)
+		}
+
+		if len(perms) != len(vhosts) {
+			return fmt.Errorf("Expected permissions in %v, got %#v", vhosts, perms)
+		}
+		for _, vhost := range vhosts {
+			found := false
+			for _, perm := range perms {
+				if perm.Vhost == vhost {
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("Unable to find permissions in vhost %s", vhost)
+			}
+		}
+
+		return nil
+	}
+}
+
+func testAccServiceAccountCheckDestroy(name string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
+
+		users, err := rmqc.ListUsers()
+		if err != nil {
+			return fmt.Errorf("Error retrieving users: %s", err)
+		}
+
+		for _, user := range users {
+			if user.Name == name {
+				return fmt.Errorf("User still exists: %s", name)
+			}
+		}
+
+		perms, err := rmqc.ListPermissions()
+		if err != nil {
+			return fmt.Errorf("Error retrieving permissions: %s", err)
+		}
+
+		for _, perm := range perms {
+			if perm.User == name {
+				return fmt.Errorf("Permissions still exist for user %s@%s", perm.User, perm.Vhost)
+			}
+		}
+
+		return nil
+	}
+}
+
+const testAccServiceAccountConfig_basic = `
+resource "rabbitmq_vhost" "test" {
+    name = "test"
+}
+
+resource "rabbitmq_service_account" "test" {
+    name = "mcservice"
+    password = "foobar"
+    tags = ["management"]
+
+    permissions {
+        vhost = "${rabbitmq_vhost.test.name}"
+        configure = "^mcservice\\."
+        write = "^mcservice\\."
+        read = ".*"
+    }
+}`
+
+const testAccServiceAccountConfig_update = `
+resource "rabbitmq_vhost" "test" {
+    name = "test"
+}
+
+resource "rabbitmq_vhost" "test2" {
+    name = "test2"
+}
+
+resource "rabbitmq_service_account" "test" {
+    name = "mcservice"
+    password = "foobar"
+    tags = ["management"]
+
+    permissions {
+        vhost = "${rabbitmq_vhost.test.name}"
+        configure = "^mcservice\\."
+        write = "^mcservice\\."
+        read = ".*"
+    }
+
+    permissions {
+        vhost = "${rabbitmq_vhost.test2.name}"
+        configure = ""
+        write = ""
+        read = ".*"
+    }
+
+    topic_permissions {
+        vhost = "${rabbitmq_vhost.test.name}"
+        exchange = "amq.topic"
+        write = "^events\\."
+        read = ".*"
+    }
+}`
@@ -3,7 +3,6 @@ package rabbitmq
 import (
 	"fmt"
 	"log"
-	"strings"
 
 	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
 
@@ -11,14 +10,17 @@ import (
 )
 
 func resourceShovel() *schema.Resource {
-	return &schema.Resource{
-		Create: CreateShovel,
-		Read:   ReadShovel,
-		Delete: DeleteShovel,
+	r := &schema.Resource{
+		Create:        CreateShovel,
+		Read:          ReadShovel,
+		Delete:        DeleteShovel,
+		CustomizeDiff: CustomizeDiffShovel,
 		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+			State: importResourceId,
 		},
 
+		SchemaVersion: 1,
+
 		Schema: map[string]*schema.Schema{
 			"name": {
 				Type:     schema.TypeString,
@@ -175,10 +177,16 @@ func resourceShovel() *schema.Resource {
 			},
 		},
 	}
+
+	r.StateUpgraders = []schema.StateUpgrader{
+		resourceIdStateUpgraderV0(r, "name"),
+	}
+
+	return r
 }
 
 func CreateShovel(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
 	vhost := d.Get("vhost").(string)
 	shovelName := d.Get("name").(string)
@@ -189,7 +197,13 @@ func CreateShovel(d *schema.ResourceData, meta interface{}) error {
 		return fmt.Errorf("Unable to parse shovel info")
 	}
 
-	shovelDefinition := setShovelDefinition(shovelMap).(rabbithole.ShovelDefinition)
+	shovelDefinition := ShovelDefinition(shovelMap)
+
+	// Brokers without AMQP 1.0 shovels don't know the protocol settings.
+	if !rmqc.version.atLeast(3, 7, 0) {
+		shovelDefinition.SourceProtocol = ""
+		shovelDefinition.DestinationProtocol = ""
+	}
 
 	log.Printf("[DEBUG] RabbitMQ: Attempting to declare shovel %s in vhost %s", shovelName, vhost)
 	resp, err := rmqc.DeclareShovel(vhost, shovelName, shovelDefinition)
@@ -198,20 +212,18 @@ func CreateShovel(d *schema.ResourceData, meta interface{}) error {
 		return err
 	}
 
-	shovelId := fmt.Sprintf("%s@%s", shovelName, vhost)
-
-	d.SetId(shovelId)
+	d.SetId(ResourceId(shovelName, vhost))
 
 	return ReadShovel(d, meta)
 }
 
 func ReadShovel(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
-	shovelId := strings.Split(d.Id(), "@")
-
-	name := shovelId[0]
-	vhost := shovelId[1]
+	name, vhost, err := parseResourceId(d)
+	if err != nil {
+		return err
+	}
 
 	shovelInfo, err := rmqc.GetShovel(vhost, name)
 	if err != nil {
@@ -256,12 +268,12 @@ func ReadShovel(d *schema.ResourceData, meta interface{}) error {
 }
 
 func DeleteShovel(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
-
-	shovelId := strings.Split(d.Id(), "@")
+	rmqc := meta.(*rabbitmqClient)
 
-	name := shovelId[0]
-	vhost := shovelId[1]
+	name, vhost, err := parseResourceId(d)
+	if err != nil {
+		return err
+	}
 
 	log.Printf("[DEBUG] RabbitMQ: Attempting to delete shovel %s", d.Id())
 
@@ -278,7 +290,52 @@ func DeleteShovel(d *schema.ResourceData, meta interface{}) error {
 	return nil
 }
 
-func setShovelDefinition(shovelMap map[string]interface{}) interface{} {
+// Shovel settings that are rejected by older brokers, with the RabbitMQ
+// version that introduced them. The src- and dest- prefixed names replaced
+// the unprefixed ones in 3.7.0, along with the AMQP 1.0 addresses; the AMQP
+// 1.0 message properties and the timestamp header of the destination came
+// with 3.8.0.
+var shovelSettingVersions = []struct {
+	key                 string
+	major, minor, patch int
+}{
+	{"destination_add_forward_headers", 3, 7, 0},
+	{"destination_add_timestamp_header", 3, 8, 0},
+	{"destination_address", 3, 7, 0},
+	{"destination_application_properties", 3, 8, 0},
+	{"destination_properties", 3, 8, 0},
+	{"destination_publish_properties", 3, 7, 0},
+	{"source_address", 3, 7, 0},
+	{"source_delete_after", 3, 7, 0},
+	{"source_prefetch_count", 3, 7, 0},
+}
+
+func CustomizeDiffShovel(d *schema.ResourceDiff, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	for _, setting := range shovelSettingVersions {
+		key := "info.0." + setting.key
+		if _, ok := d.GetOk(key); ok {
+			if err := rmqc.requireVersion(key, setting.major, setting.minor, setting.patch); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, key := range []string{"info.0.source_protocol", "info.0.destination_protocol"} {
+		if d.Get(key).(string) == "amqp10" {
+			if err := rmqc.requireVersion(key+" = amqp10", 3, 7, 0); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ShovelDefinition returns the definition of an info block, as it is
+// declared in RabbitMQ.
+func ShovelDefinition(shovelMap map[string]interface{}) rabbithole.ShovelDefinition {
 	shovelDefinition := &rabbithole.ShovelDefinition{}
 
 	if v, ok := shovelMap["ack_mode"].(string); ok {
@@ -2,7 +2,6 @@ package rabbitmq
 
 import (
 	"fmt"
-	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
@@ -39,8 +38,11 @@ func testAccShovelCheck(rn string, shovelInfo *rabbithole.ShovelInfo) resource.T
 			return fmt.Errorf("shovel id not set")
 		}
 
-		rmqc := testAccProvider.Meta().(*rabbithole.Client)
-		shovelParts := strings.Split(rs.Primary.ID, "@")
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
+		name, vhost, err := parseId(rs.Primary.ID)
+		if err != nil {
+			return err
+		}
 
 		shovelInfos, err := rmqc.ListShovels()
 		if err != nil {
@@ -48,7 +50,7 @@ func testAccShovelCheck(rn string, shovelInfo *rabbithole.ShovelInfo) resource.T
 		}
 
 		for _, info := range shovelInfos {
-			if info.Name == shovelParts[0] && info.Vhost == shovelParts[1] {
+			if info.Name == name && info.Vhost == vhost {
 				shovelInfo = &info
 				return nil
 			}
@@ -60,7 +62,7 @@ func testAccShovelCheck(rn string, shovelInfo *rabbithole.ShovelInfo) resource.T
 
 func testAccShovelCheckDestroy(shovelInfo *rabbithole.ShovelInfo) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
-		rmqc := testAccProvider.Meta().(*rabbithole.Client)
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
 
 		shovelInfos, err := rmqc.ListShovels()
 		if err != nil {
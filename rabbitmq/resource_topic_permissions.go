@@ -3,7 +3,6 @@ package rabbitmq
 import (
 	"fmt"
 	"log"
-	"strconv"
 
 	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
 
@@ -11,15 +10,18 @@ import (
 )
 
 func resourceTopicPermissions() *schema.Resource {
-	return &schema.Resource{
-		Create: CreateTopicPermissions,
-		Update: UpdateTopicPermissions,
-		Read:   ReadTopicPermissions,
-		Delete: DeleteTopicPermissions,
+	r := &schema.Resource{
+		Create:        CreateTopicPermissions,
+		Update:        UpdateTopicPermissions,
+		Read:          ReadTopicPermissions,
+		Delete:        DeleteTopicPermissions,
+		CustomizeDiff: CustomizeDiffTopicPermissions,
 		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+			State: importResourceId,
 		},
 
+		SchemaVersion: 1,
+
 		Schema: map[string]*schema.Schema{
 			"user": {
 				Type:     schema.TypeString,
@@ -45,24 +47,32 @@ func resourceTopicPermissions() *schema.Resource {
 						},
 
 						"write": {
-							Type:     schema.TypeString,
-							Required: true,
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validatePermissionRegexp(true),
 						},
 
 						"read": {
-							Type:     schema.TypeString,
-							Required: true,
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validatePermissionRegexp(true),
 						},
 					},
 				},
 			},
 		},
 	}
+
+	r.StateUpgraders = []schema.StateUpgrader{
+		resourceIdStateUpgraderV0(r, "user"),
+	}
+
+	return r
 }
 
 // CreateTopicPermissions for given exchanges
 func CreateTopicPermissions(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
 	user := d.Get("user").(string)
 	vhost := d.Get("vhost").(string)
@@ -80,7 +90,7 @@ func CreateTopicPermissions(d *schema.ResourceData, meta interface{}) error {
 		}
 	}
 
-	id := fmt.Sprintf("%s@%s", user, vhost)
+	id := ResourceId(user, vhost)
 	d.SetId(id)
 
 	return ReadTopicPermissions(d, meta)
@@ -88,9 +98,9 @@ func CreateTopicPermissions(d *schema.ResourceData, meta interface{}) error {
 
 // ReadTopicPermissions for the given ID
 func ReadTopicPermissions(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
-	user, vhost, err := parseID(d)
+	user, vhost, err := parseResourceId(d)
 	if err != nil {
 		return err
 	}
@@ -102,6 +112,12 @@ func ReadTopicPermissions(d *schema.ResourceData, meta interface{}) error {
 
 	log.Printf("[DEBUG] RabbitMQ: Topic permission retrieved for %s: %#v", d.Id(), userPerms)
 
+	// All topic permissions were removed outside of Terraform
+	if len(userPerms) == 0 {
+		d.SetId("")
+		return nil
+	}
+
 	d.Set("user", userPerms[0].User)
 	d.Set("vhost", userPerms[0].Vhost)
 
@@ -120,21 +136,20 @@ func ReadTopicPermissions(d *schema.ResourceData, meta interface{}) error {
 
 // UpdateTopicPermissions for given ID
 func UpdateTopicPermissions(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
-	user, vhost, err := parseID(d)
+	user, vhost, err := parseResourceId(d)
 	if err != nil {
 		return err
 	}
 
 	if d.HasChange("permissions") {
-		err = DeleteTopicPermissions(d, meta)
-		if err != nil {
-			return err
-		}
-		_, newPerms := d.GetChange("permissions")
-		newPermsSet := newPerms.(*schema.Set)
-		for _, exchange := range newPermsSet.List() {
+		oldPerms, newPerms := d.GetChange("permissions")
+
+		// Permissions are updated exchange by exchange, so that publishers
+		// are never denied while the remaining exchanges are updated.
+		newExchanges := map[string]bool{}
+		for _, exchange := range newPerms.(*schema.Set).List() {
 			permsMap, ok := exchange.(map[string]interface{})
 			if !ok {
 				return fmt.Errorf("Unable to parse permissions")
@@ -143,6 +158,20 @@ func UpdateTopicPermissions(d *schema.ResourceData, meta interface{}) error {
 			if err := setTopicPermissionsIn(rmqc, vhost, user, permsMap); err != nil {
 				return err
 			}
+			newExchanges[permsMap["exchange"].(string)] = true
+		}
+
+		for _, exchange := range oldPerms.(*schema.Set).List() {
+			permsMap, ok := exchange.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("Unable to parse permissions")
+			}
+
+			if name := permsMap["exchange"].(string); !newExchanges[name] {
+				if err := deleteTopicPermissionsIn(rmqc, vhost, user, name); err != nil {
+					return err
+				}
+			}
 		}
 	}
 
@@ -151,9 +180,9 @@ func UpdateTopicPermissions(d *schema.ResourceData, meta interface{}) error {
 
 // DeleteTopicPermissions for given ID
 func DeleteTopicPermissions(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
-	user, vhost, err := parseID(d)
+	user, vhost, err := parseResourceId(d)
 	if err != nil {
 		return err
 	}
@@ -172,17 +201,41 @@ func DeleteTopicPermissions(d *schema.ResourceData, meta interface{}) error {
 	}
 
 	if resp.StatusCode >= 400 {
-		verErr := checkVersion(rmqc)
-		if verErr != nil {
-			return verErr
-		}
 		return fmt.Errorf("Error deleting RabbitMQ topic permission: %s", resp.Status)
 	}
 
 	return nil
 }
 
-func setTopicPermissionsIn(rmqc *rabbithole.Client, vhost string, user string, permsMap map[string]interface{}) error {
+func deleteTopicPermissionsIn(rmqc *rabbitmqClient, vhost string, user string, exchange string) error {
+	log.Printf("[DEBUG] RabbitMQ: Attempting to delete topic permissions for %s@%s on exchange %s", user, vhost, exchange)
+
+	resp, err := rmqc.DeleteTopicPermissionsIn(vhost, user, exchange)
+	log.Printf("[DEBUG] RabbitMQ: Topic permission delete response: %#v", resp)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == 404 {
+		// The permissions were already deleted
+		return nil
+	}
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("Error deleting RabbitMQ topic permission: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// CustomizeDiffTopicPermissions fails the plan on brokers without topic permissions
+func CustomizeDiffTopicPermissions(d *schema.ResourceDiff, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	return rmqc.requireVersion("rabbitmq_topic_permissions", 3, 7, 0)
+}
+
+func setTopicPermissionsIn(rmqc *rabbitmqClient, vhost string, user string, permsMap map[string]interface{}) error {
 	perms := rabbithole.TopicPermissions{}
 
 	if v, ok := permsMap["exchange"].(string); ok {
@@ -206,21 +259,8 @@ func setTopicPermissionsIn(rmqc *rabbithole.Client, vhost string, user string, p
 	}
 
 	if resp.StatusCode >= 400 {
-		verErr := checkVersion(rmqc)
-		if verErr != nil {
-			return verErr
-		}
 		return fmt.Errorf("Error setting topic permissions: %s", resp.Status)
 	}
 
 	return nil
 }
-
-func checkVersion(rmqc *rabbithole.Client) error {
-	overview, _ := rmqc.Overview()
-	ver, _ := strconv.ParseFloat(overview.RabbitMQVersion, 32)
-	if ver < 3.7 {
-		return fmt.Errorf("Topic permissions were adding in RabbitMQ 3.7, connected to %s", overview.RabbitMQVersion)
-	}
-	return nil
-}
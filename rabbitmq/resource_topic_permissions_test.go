@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 	"regexp"
-	"strings"
 	"testing"
 
 	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
@@ -18,7 +17,7 @@ func TestAccTopicPermissions(t *testing.T) {
 	var expectErr *regexp.Regexp
 	checkDestroy := testAccTopicPermissionsCheckDestroy(&topicPermissionInfo)
 	if os.Getenv("RABBITMQ_VERSION") == "3.6" {
-		expectErr, _ = regexp.Compile("^errors during apply: Topic permissions were adding in RabbitMQ 3.7, connected to 3.6.*$")
+		expectErr, _ = regexp.Compile(`rabbitmq_topic_permissions requires RabbitMQ >= 3\.7\.0, connected to 3\.6`)
 		checkDestroy = nil
 	}
 	resource.Test(t, resource.TestCase{
@@ -44,6 +43,41 @@ func TestAccTopicPermissions(t *testing.T) {
 	})
 }
 
+func TestAccTopicPermissions_removedOutOfBand(t *testing.T) {
+	var topicPermissionInfo rabbithole.TopicPermissionInfo
+	if os.Getenv("RABBITMQ_VERSION") == "3.6" {
+		t.Skip("Topic permissions require RabbitMQ 3.7")
+	}
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccTopicPermissionsCheckDestroy(&topicPermissionInfo),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccTopicPermissionsConfigBasic,
+				Check: testAccTopicPermissionsCheck(
+					"rabbitmq_topic_permissions.test", &topicPermissionInfo,
+				),
+			},
+			{
+				PreConfig: func() {
+					rmqc := testAccProvider.Meta().(*rabbitmqClient)
+					rmqc.ClearTopicPermissionsIn("test", "mctest")
+				},
+				Config:             testAccTopicPermissionsConfigBasic,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: testAccTopicPermissionsConfigBasic,
+				Check: testAccTopicPermissionsCheck(
+					"rabbitmq_topic_permissions.test", &topicPermissionInfo,
+				),
+			},
+		},
+	})
+}
+
 func testAccTopicPermissionsCheck(rn string, topicPermissionInfo *rabbithole.TopicPermissionInfo) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[rn]
@@ -55,15 +89,18 @@ func testAccTopicPermissionsCheck(rn string, topicPermissionInfo *rabbithole.Top
 			return fmt.Errorf("permission id not set")
 		}
 
-		rmqc := testAccProvider.Meta().(*rabbithole.Client)
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
 		perms, err := rmqc.ListTopicPermissions()
 		if err != nil {
 			return fmt.Errorf("Error retrieving topic permissions: %s", err)
 		}
 
-		userParts := strings.Split(rs.Primary.ID, "@")
+		user, vhost, err := parseId(rs.Primary.ID)
+		if err != nil {
+			return err
+		}
 		for _, perm := range perms {
-			if perm.User == userParts[0] && perm.Vhost == userParts[1] {
+			if perm.User == user && perm.Vhost == vhost {
 				topicPermissionInfo = &perm
 				return nil
 			}
@@ -75,7 +112,7 @@ func testAccTopicPermissionsCheck(rn string, topicPermissionInfo *rabbithole.Top
 
 func testAccTopicPermissionsCheckDestroy(topicPermissionInfo *rabbithole.TopicPermissionInfo) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
-		rmqc := testAccProvider.Meta().(*rabbithole.Client)
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
 		perms, err := rmqc.ListTopicPermissions()
 		if err != nil {
 			return fmt.Errorf("Error retrieving topic permissions: %s", err)
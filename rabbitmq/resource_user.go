@@ -1,60 +1,121 @@
 package rabbitmq
 
 import (
+	"encoding/base64"
 	"fmt"
 	"log"
+	"net/http"
+	"sort"
 	"strings"
 
 	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
 )
 
 func resourceUser() *schema.Resource {
 	return &schema.Resource{
-		Create: CreateUser,
-		Update: UpdateUser,
-		Read:   ReadUser,
-		Delete: DeleteUser,
+		Create:        CreateUser,
+		Update:        UpdateUser,
+		Read:          ReadUser,
+		Delete:        DeleteUser,
+		CustomizeDiff: CustomizeDiffUser,
 		Importer: &schema.ResourceImporter{
 			State: schema.ImportStatePassthrough,
 		},
 
-		Schema: map[string]*schema.Schema{
-			"name": {
-				Type:     schema.TypeString,
-				Required: true,
-				ForceNew: true,
+		SchemaVersion: 1,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Type:    resourceUserV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: resourceUserStateUpgradeV0,
+				Version: 0,
 			},
+		},
 
-			"password": {
-				Type:      schema.TypeString,
-				Required:  true,
-				Sensitive: true,
-			},
+		Schema: userSchema(),
+	}
+}
 
-			"tags": {
-				Type:     schema.TypeList,
-				Optional: true,
-				Elem:     &schema.Schema{Type: schema.TypeString},
+// userSchema returns the schema of a user, shared with the resources that
+// manage users along with other objects.
+func userSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"name": {
+			Type:     schema.TypeString,
+			Required: true,
+			ForceNew: true,
+		},
+
+		"password": {
+			Type:          schema.TypeString,
+			Optional:      true,
+			Sensitive:     true,
+			ConflictsWith: []string{"password_hash", "passwordless"},
+		},
+
+		"password_hash": {
+			Type:          schema.TypeString,
+			Optional:      true,
+			Sensitive:     true,
+			ConflictsWith: []string{"password", "passwordless"},
+		},
+
+		"hashing_algorithm": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Computed:     true,
+			ValidateFunc: validation.StringInSlice(userHashingAlgorithms, false),
+		},
+
+		"detect_password_drift": {
+			Type:     schema.TypeBool,
+			Optional: true,
+			Default:  false,
+		},
+
+		"passwordless": {
+			Type:          schema.TypeBool,
+			Optional:      true,
+			Default:       false,
+			ConflictsWith: []string{"password", "password_hash"},
+		},
+
+		"tags": {
+			Type:     schema.TypeSet,
+			Optional: true,
+			Elem: &schema.Schema{
+				Type:         schema.TypeString,
+				ValidateFunc: validateUserTag,
 			},
 		},
 	}
 }
 
+// Tags known by RabbitMQ. Other tags are allowed, for example to map OAuth 2.0
+// scopes, but are most likely typos.
+var userKnownTags = []string{
+	"administrator",
+	"monitoring",
+	"policymaker",
+	"management",
+	"impersonator",
+}
+
+var userHashingAlgorithms = []string{
+	rabbithole.HashingAlgorithmSHA256.String(),
+	rabbithole.HashingAlgorithmSHA512.String(),
+}
+
 func CreateUser(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
 	name := d.Get("name").(string)
 
-	userSettings := rabbithole.UserSettings{
-		Password: d.Get("password").(string),
-		Tags:     userTagsToString(d),
-	}
-
 	log.Printf("[DEBUG] RabbitMQ: Attempting to create user %s", name)
 
-	resp, err := rmqc.PutUser(name, userSettings)
+	resp, err := putUser(rmqc, name, d)
 	log.Printf("[DEBUG] RabbitMQ: user creation response: %#v", resp)
 	if err != nil {
 		return err
@@ -70,40 +131,71 @@ func CreateUser(d *schema.ResourceData, meta interface{}) error {
 }
 
 func ReadUser(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
 	user, err := rmqc.GetUser(d.Id())
 	if err != nil {
 		return checkDeleted(d, err)
 	}
 
-	log.Printf("[DEBUG] RabbitMQ: User retrieved: %#v", user)
+	log.Printf("[DEBUG] RabbitMQ: User retrieved: %s", user.Name)
+
+	setUserData(d, user)
 
+	return nil
+}
+
+// setUserData updates the user attributes of d from the user read from the broker
+func setUserData(d *schema.ResourceData, user *rabbithole.UserInfo) {
 	d.Set("name", user.Name)
+	d.Set("passwordless", user.PasswordHash == "")
 
-	if len(user.Tags) > 0 {
-		tags := strings.Split(user.Tags, ",")
-		d.Set("tags", tags)
+	// The hash is only tracked when the user is managed by its hash,
+	// otherwise every read would show a diff against the plain-text password.
+	if _, ok := d.GetOk("password_hash"); ok {
+		d.Set("password_hash", user.PasswordHash)
+		d.Set("hashing_algorithm", user.HashingAlgorithm.String())
 	}
 
-	return nil
+	// Defaults aren't applied on import, so the setting is stored as it is
+	// read, false when unset.
+	detectDrift := d.Get("detect_password_drift").(bool)
+	d.Set("detect_password_drift", detectDrift)
+
+	if password, ok := d.GetOk("password"); ok && detectDrift {
+		if !userPasswordMatches(user, password.(string)) {
+			log.Printf("[DEBUG] RabbitMQ: Password of user %s was changed outside of Terraform", user.Name)
+			d.Set("password", "")
+		}
+	}
+
+	// Empty tags in the configuration are never stored by the broker, so they
+	// are ignored when comparing the tags.
+	tags := schema.NewSet(schema.HashString, nil)
+	for _, tag := range strings.Split(user.Tags, ",") {
+		if tag != "" {
+			tags.Add(tag)
+		}
+	}
+	configured := schema.NewSet(schema.HashString, nil)
+	for _, tag := range d.Get("tags").(*schema.Set).List() {
+		if tag != "" {
+			configured.Add(tag)
+		}
+	}
+	if !tags.Equal(configured) {
+		d.Set("tags", tags)
+	}
 }
 
 func UpdateUser(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
 	name := d.Id()
-	tags := userTagsToString(d)
-	password := d.Get("password").(string)
-
-	userSettings := rabbithole.UserSettings{
-		Password: password,
-		Tags:     tags,
-	}
 
 	log.Printf("[DEBUG] RabbitMQ: Attempting to update user %s", name)
 
-	resp, err := rmqc.PutUser(name, userSettings)
+	resp, err := putUser(rmqc, name, d)
 	log.Printf("[DEBUG] RabbitMQ: User update response: %#v", resp)
 	if err != nil {
 		return err
@@ -117,7 +209,7 @@ func UpdateUser(d *schema.ResourceData, meta interface{}) error {
 }
 
 func DeleteUser(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
 	name := d.Id()
 	log.Printf("[DEBUG] RabbitMQ: Attempting to delete user %s", name)
@@ -140,15 +232,130 @@ func DeleteUser(d *schema.ResourceData, meta interface{}) error {
 	return nil
 }
 
+// CustomizeDiffUser makes sure the user has exactly one way to authenticate
+func CustomizeDiffUser(d *schema.ResourceDiff, meta interface{}) error {
+	for _, key := range []string{"password", "password_hash"} {
+		if !d.NewValueKnown(key) {
+			return nil
+		}
+	}
+
+	_, hasPassword := d.GetOk("password")
+	_, hasHash := d.GetOk("password_hash")
+	if !hasPassword && !hasHash && !d.Get("passwordless").(bool) {
+		return fmt.Errorf("One of password, password_hash or passwordless must be set")
+	}
+
+	return nil
+}
+
+func putUser(rmqc *rabbitmqClient, name string, d *schema.ResourceData) (*http.Response, error) {
+	userSettings := rabbithole.UserSettings{
+		Tags: userTagsToString(d),
+	}
+
+	if d.Get("passwordless").(bool) {
+		return rmqc.PutUserWithoutPassword(name, userSettings)
+	}
+
+	if v, ok := d.GetOk("password_hash"); ok {
+		userSettings.PasswordHash = v.(string)
+		userSettings.HashingAlgorithm = rabbithole.HashingAlgorithmSHA256
+		if v, ok := d.GetOk("hashing_algorithm"); ok {
+			userSettings.HashingAlgorithm = rabbithole.HashingAlgorithm(v.(string))
+		}
+	} else {
+		userSettings.Password = d.Get("password").(string)
+	}
+
+	return rmqc.PutUser(name, userSettings)
+}
+
+// userPasswordMatches re-hashes password with the salt of the hash stored by
+// the broker, and reports whether both hashes are the same.
+func userPasswordMatches(user *rabbithole.UserInfo, password string) bool {
+	stored, err := base64.StdEncoding.DecodeString(user.PasswordHash)
+	if err != nil || len(stored) < 4 {
+		return false
+	}
+
+	hash, err := hashPassword(password, string(stored[:4]), user.HashingAlgorithm)
+	if err != nil {
+		// The hash can't be verified, for example with legacy MD5 hashes.
+		log.Printf("[WARN] RabbitMQ: Unable to verify the password of user %s: %s", user.Name, err)
+		return true
+	}
+
+	return hash == user.PasswordHash
+}
+
 func userTagsToString(d *schema.ResourceData) string {
 	var tags string
 	tagList := []string{}
-	for _, v := range d.Get("tags").([]interface{}) {
-		if tag, ok := v.(string); ok {
+	for _, v := range d.Get("tags").(*schema.Set).List() {
+		if tag, ok := v.(string); ok && tag != "" {
 			tagList = append(tagList, tag)
 		}
 	}
+	sort.Strings(tagList)
 	tags = strings.Join(tagList, ",")
 
 	return tags
 }
+
+func validateUserTag(v interface{}, k string) (ws []string, errors []error) {
+	value := v.(string)
+	if value == "" {
+		return
+	}
+
+	for _, tag := range userKnownTags {
+		if value == tag {
+			return
+		}
+	}
+
+	ws = append(ws, fmt.Sprintf("%q: %q is not a tag known by RabbitMQ (%s)", k, value, strings.Join(userKnownTags, ", ")))
+	return
+}
+
+// The tags used to be a list.
+func resourceUserV0() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+
+			"password": {
+				Type:      schema.TypeString,
+				Required:  true,
+				Sensitive: true,
+			},
+
+			"tags": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+func resourceUserStateUpgradeV0(rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	tags := []interface{}{}
+	seen := map[string]bool{}
+	if v, ok := rawState["tags"].([]interface{}); ok {
+		for _, tag := range v {
+			if t, ok := tag.(string); ok && !seen[t] {
+				seen[t] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+	rawState["tags"] = tags
+
+	return rawState, nil
+}
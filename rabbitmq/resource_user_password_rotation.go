@@ -0,0 +1,461 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+)
+
+// resourceUserPasswordRotation manages a user whose password is generated by
+// the provider. RabbitMQ users have a single password, so every rotation
+// creates a new user named after the generation, e.g. app-2, and the previous
+// user is kept for the configured overlap so that clients can switch over.
+func resourceUserPasswordRotation() *schema.Resource {
+	return &schema.Resource{
+		Create:        CreateUserPasswordRotation,
+		Update:        UpdateUserPasswordRotation,
+		Read:          ReadUserPasswordRotation,
+		Delete:        DeleteUserPasswordRotation,
+		CustomizeDiff: CustomizeDiffUserPasswordRotation,
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+
+			"tags": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validateUserTag,
+				},
+			},
+
+			"permissions": userPermissionsSchema(),
+
+			"length": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      32,
+				ValidateFunc: validation.IntBetween(16, 128),
+			},
+
+			"rotation_period": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateDuration,
+			},
+
+			"overlap": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "0s",
+				ValidateFunc: validateDuration,
+			},
+
+			"keepers": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+
+			"generation": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+
+			"username": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"password": {
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
+
+			"previous_username": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"previous_password": {
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
+
+			"rotated_at": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func CreateUserPasswordRotation(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	name := d.Get("name").(string)
+
+	username, password, err := createRotatedUser(rmqc, name, 1, d)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(name)
+	d.Set("generation", 1)
+	d.Set("username", username)
+	d.Set("password", password)
+	d.Set("previous_username", "")
+	d.Set("previous_password", "")
+	d.Set("rotated_at", time.Now().UTC().Format(time.RFC3339))
+
+	return ReadUserPasswordRotation(d, meta)
+}
+
+func ReadUserPasswordRotation(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	// The previous user may have been deleted by hand once every client moved
+	// to the current one.
+	previous := d.Get("previous_username").(string)
+	if previous != "" {
+		if _, err := rmqc.GetUser(previous); err != nil {
+			if rmqErr, ok := err.(rabbithole.ErrorResponse); ok && rmqErr.StatusCode == 404 {
+				previous = ""
+				d.Set("previous_username", "")
+				d.Set("previous_password", "")
+			} else {
+				return err
+			}
+		}
+	}
+
+	d.Set("name", d.Id())
+
+	username := d.Get("username").(string)
+	if username != "" {
+		user, err := rmqc.GetUser(username)
+		if err == nil {
+			return readRotatedUser(rmqc, d, user)
+		}
+		if rmqErr, ok := err.(rabbithole.ErrorResponse); !ok || rmqErr.StatusCode != 404 {
+			return err
+		}
+
+		log.Printf("[WARN] RabbitMQ: User %s not found", username)
+		d.Set("username", "")
+		d.Set("password", "")
+	}
+
+	// Without a current user, the resource is kept while the previous user
+	// exists, so that it is deleted along with the resource. The next apply
+	// rotates the password, which creates a new current user.
+	if previous == "" {
+		d.SetId("")
+	}
+
+	return nil
+}
+
+// readRotatedUser sets the tags and permissions of the current user.
+func readRotatedUser(rmqc *rabbitmqClient, d *schema.ResourceData, user *rabbithole.UserInfo) error {
+	log.Printf("[DEBUG] RabbitMQ: User retrieved: %s", user.Name)
+
+	tags := schema.NewSet(schema.HashString, nil)
+	for _, tag := range strings.Split(user.Tags, ",") {
+		if tag != "" {
+			tags.Add(tag)
+		}
+	}
+	d.Set("tags", tags)
+
+	perms, err := rmqc.ListPermissionsOf(user.Name)
+	if err != nil {
+		return err
+	}
+
+	permissions := make([]map[string]interface{}, 0, len(perms))
+	for _, perm := range perms {
+		permissions = append(permissions, map[string]interface{}{
+			"vhost":     perm.Vhost,
+			"configure": perm.Configure,
+			"write":     perm.Write,
+			"read":      perm.Read,
+		})
+	}
+	d.Set("permissions", permissions)
+
+	return nil
+}
+
+func UpdateUserPasswordRotation(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	name := d.Id()
+	// Computed attributes are unknown when a rotation is planned, so the
+	// values before the update are used.
+	o, _ := d.GetChange("generation")
+	generation := o.(int)
+	o, _ = d.GetChange("username")
+	username := o.(string)
+	o, _ = d.GetChange("password")
+	password := o.(string)
+	o, _ = d.GetChange("previous_username")
+	previous := o.(string)
+
+	// Rotations are decided when planning, see
+	// CustomizeDiffUserPasswordRotation.
+	if d.HasChange("generation") {
+		log.Printf("[DEBUG] RabbitMQ: Rotating password of %s", name)
+
+		// Only one previous user is kept, so the overlap of the rotation
+		// before ends now.
+		if previous != "" {
+			if err := deleteRotatedUser(rmqc, previous); err != nil {
+				return err
+			}
+		}
+
+		newUsername, newPassword, err := createRotatedUser(rmqc, name, generation+1, d)
+		if err != nil {
+			return err
+		}
+
+		d.Set("generation", generation+1)
+		d.Set("username", newUsername)
+		d.Set("password", newPassword)
+		d.Set("previous_username", username)
+		d.Set("previous_password", password)
+		d.Set("rotated_at", time.Now().UTC().Format(time.RFC3339))
+
+		// The overlap starts with the rotation, so a zero overlap ends it
+		// right away.
+		if username != "" && userPasswordPeriodElapsed(d.Get("overlap").(string), d.Get("rotated_at").(string), time.Now()) {
+			if err := deleteRotatedUser(rmqc, username); err != nil {
+				return err
+			}
+			d.Set("previous_username", "")
+			d.Set("previous_password", "")
+		}
+
+		return ReadUserPasswordRotation(d, meta)
+	}
+
+	if d.HasChange("tags") || d.HasChange("permissions") {
+		for _, user := range []string{username, previous} {
+			if user == "" {
+				continue
+			}
+			if err := updateRotatedUser(rmqc, user, d); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The end of the overlap is planned by clearing previous_username.
+	if previous != "" && d.Get("previous_username").(string) == "" {
+		if err := deleteRotatedUser(rmqc, previous); err != nil {
+			return err
+		}
+		d.Set("previous_password", "")
+	}
+
+	return ReadUserPasswordRotation(d, meta)
+}
+
+func DeleteUserPasswordRotation(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	// Permissions are deleted by RabbitMQ along with the users.
+	for _, key := range []string{"previous_username", "username"} {
+		if user := d.Get(key).(string); user != "" {
+			if err := deleteRotatedUser(rmqc, user); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// CustomizeDiffUserPasswordRotation plans a rotation once the rotation period
+// has passed, when the keepers change or when the current user was deleted,
+// and the removal of the previous user once the overlap has passed. Updates
+// only rotate the password when a new generation is planned.
+func CustomizeDiffUserPasswordRotation(d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" {
+		return nil
+	}
+
+	rotatedAt := d.Get("rotated_at").(string)
+	now := time.Now()
+
+	if d.HasChange("keepers") || d.HasChange("length") || d.Get("username").(string) == "" || userPasswordPeriodElapsed(d.Get("rotation_period").(string), rotatedAt, now) {
+		for _, key := range []string{"generation", "username", "password", "previous_username", "previous_password", "rotated_at"} {
+			if err := d.SetNewComputed(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if d.Get("previous_username").(string) != "" && userPasswordPeriodElapsed(d.Get("overlap").(string), rotatedAt, now) {
+		if err := d.SetNew("previous_username", ""); err != nil {
+			return err
+		}
+		if err := d.SetNew("previous_password", ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// userPasswordPeriodElapsed returns true once period has passed since the
+// given time. An empty period never elapses: without a rotation period,
+// passwords are only rotated when the keepers change.
+func userPasswordPeriodElapsed(period string, since string, now time.Time) bool {
+	if period == "" || since == "" {
+		return false
+	}
+
+	duration, err := time.ParseDuration(period)
+	if err != nil {
+		return false
+	}
+
+	t, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return false
+	}
+
+	return !now.Before(t.Add(duration))
+}
+
+func createRotatedUser(rmqc *rabbitmqClient, name string, generation int, d *schema.ResourceData) (string, string, error) {
+	password, err := generatePassword(d.Get("length").(int))
+	if err != nil {
+		return "", "", err
+	}
+
+	username := fmt.Sprintf("%s-%d", name, generation)
+
+	// Users of other generations are never overwritten, since their
+	// passwords may still be in use.
+	_, err = rmqc.GetUser(username)
+	if err == nil {
+		return "", "", fmt.Errorf("Unable to create RabbitMQ user %s: the user already exists", username)
+	}
+	if rmqErr, ok := err.(rabbithole.ErrorResponse); !ok || rmqErr.StatusCode != 404 {
+		return "", "", err
+	}
+
+	log.Printf("[DEBUG] RabbitMQ: Attempting to create user %s", username)
+
+	resp, err := rmqc.PutUser(username, rabbithole.UserSettings{
+		Password: password,
+		Tags:     userTagsToString(d),
+	})
+	log.Printf("[DEBUG] RabbitMQ: user creation response: %#v", resp)
+	if err != nil {
+		return "", "", err
+	}
+
+	if resp.StatusCode >= 400 {
+		return "", "", fmt.Errorf("Error creating RabbitMQ user: %s", resp.Status)
+	}
+
+	for _, perm := range d.Get("permissions").(*schema.Set).List() {
+		permsMap := perm.(map[string]interface{})
+		if err := setPermissionsIn(rmqc, permsMap["vhost"].(string), username, permsMap); err != nil {
+			// The ID isn't set yet, so the user would be orphaned and block
+			// later applies from creating it again.
+			if delErr := deleteRotatedUser(rmqc, username); delErr != nil {
+				return "", "", fmt.Errorf("%s (also failed to delete RabbitMQ user %s: %s)", err, username, delErr)
+			}
+			return "", "", err
+		}
+	}
+
+	return username, password, nil
+}
+
+// updateRotatedUser applies the tags and permissions to an existing user
+// without changing its password.
+func updateRotatedUser(rmqc *rabbitmqClient, username string, d *schema.ResourceData) error {
+	user, err := rmqc.GetUser(username)
+	if err != nil {
+		return err
+	}
+
+	resp, err := rmqc.PutUser(username, rabbithole.UserSettings{
+		PasswordHash:     user.PasswordHash,
+		HashingAlgorithm: user.HashingAlgorithm,
+		Tags:             userTagsToString(d),
+	})
+	log.Printf("[DEBUG] RabbitMQ: User update response: %#v", resp)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("Error updating RabbitMQ user: %s", resp.Status)
+	}
+
+	o, n := d.GetChange("permissions")
+
+	kept := map[string]bool{}
+	for _, perm := range n.(*schema.Set).List() {
+		permsMap := perm.(map[string]interface{})
+		vhost := permsMap["vhost"].(string)
+		kept[vhost] = true
+		if err := setPermissionsIn(rmqc, vhost, username, permsMap); err != nil {
+			return err
+		}
+	}
+
+	for _, perm := range o.(*schema.Set).List() {
+		vhost := perm.(map[string]interface{})["vhost"].(string)
+		if kept[vhost] {
+			continue
+		}
+		if err := clearPermissionsIn(rmqc, vhost, username); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func deleteRotatedUser(rmqc *rabbitmqClient, username string) error {
+	log.Printf("[DEBUG] RabbitMQ: Attempting to delete user %s", username)
+
+	resp, err := rmqc.DeleteUser(username)
+	log.Printf("[DEBUG] RabbitMQ: User delete response: %#v", resp)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == 404 {
+		// the user was already deleted
+		return nil
+	}
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("Error deleting RabbitMQ user: %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+)
+
+func TestCreateRotatedUser_permissionsFailure(t *testing.T) {
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/users/app-1":
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == "PUT" && r.URL.Path == "/api/users/app-1":
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == "PUT" && r.URL.Path == "/api/permissions/test/app-1":
+			w.WriteHeader(http.StatusInternalServerError)
+		case r.Method == "DELETE" && r.URL.Path == "/api/users/app-1":
+			deleted = append(deleted, "app-1")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	client, err := rabbithole.NewClient(server.URL, "guest", "guest")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	rmqc := &rabbitmqClient{Client: client}
+
+	d := schema.TestResourceDataRaw(t, resourceUserPasswordRotation().Schema, map[string]interface{}{
+		"name": "app",
+		"permissions": []interface{}{
+			map[string]interface{}{
+				"vhost":     "test",
+				"configure": ".*",
+				"write":     ".*",
+				"read":      ".*",
+			},
+		},
+	})
+
+	if _, _, err := createRotatedUser(rmqc, "app", 1, d); err == nil {
+		t.Fatalf("Expected an error when permissions can't be granted")
+	}
+
+	if len(deleted) != 1 {
+		t.Fatalf("Expected the user to be deleted after the failed grant, deleted: %v", deleted)
+	}
+}
+
+func TestAccUserPasswordRotation_keepers(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccUserPasswordRotationCheckDestroy("mcrotated-1", "mcrotated-2", "mcrotated-3"),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccUserPasswordRotationConfig("1"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("rabbitmq_user_password_rotation.test", "username", "mcrotated-1"),
+					resource.TestCheckResourceAttr("rabbitmq_user_password_rotation.test", "previous_username", ""),
+					testAccUserPasswordRotationConnect("rabbitmq_user_password_rotation.test", "username", "password"),
+				),
+			},
+			{
+				Config: testAccUserPasswordRotationConfig("2"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("rabbitmq_user_password_rotation.test", "username", "mcrotated-2"),
+					resource.TestCheckResourceAttr("rabbitmq_user_password_rotation.test", "previous_username", "mcrotated-1"),
+					testAccUserPasswordRotationConnect("rabbitmq_user_password_rotation.test", "username", "password"),
+					testAccUserPasswordRotationConnect("rabbitmq_user_password_rotation.test", "previous_username", "previous_password"),
+				),
+			},
+			{
+				Config: testAccUserPasswordRotationConfig("3"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("rabbitmq_user_password_rotation.test", "username", "mcrotated-3"),
+					resource.TestCheckResourceAttr("rabbitmq_user_password_rotation.test", "previous_username", "mcrotated-2"),
+					testAccUserPasswordRotationCheckDestroy("mcrotated-1"),
+				),
+			},
+		},
+	})
+}
+
+func TestUserPasswordPeriodElapsed(t *testing.T) {
+	now := time.Date(2020, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	var inputs = []struct {
+		period   string
+		since    string
+		expected bool
+	}{
+		{"", "2020-01-01T12:00:00Z", false},
+		{"720h", "", false},
+		{"720h", "2020-01-01T12:00:00Z", true},
+		{"720h", "2020-01-01T12:00:01Z", false},
+		{"0s", "2020-01-31T12:00:00Z", true},
+		{"1h", "2020-01-31T11:30:00Z", false},
+	}
+
+	for _, test := range inputs {
+		if output := userPasswordPeriodElapsed(test.period, test.since, now); output != test.expected {
+			t.Errorf("userPasswordPeriodElapsed failed for: %s since %s. Got %t", test.period, test.since, output)
+		}
+	}
+}
+
+func testAccUserPasswordRotationConnect(rn, usernameKey, passwordKey string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[rn]
+		if !ok {
+			return fmt.Errorf("resource not found: %s", rn)
+		}
+
+		return testAccUserConnect(rs.Primary.Attributes[usernameKey], rs.Primary.Attributes[passwordKey])(s)
+	}
+}
+
+func testAccUserPasswordRotationCheckDestroy(usernames ...string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
+
+		users, err := rmqc.ListUsers()
+		if err != nil {
+			return fmt.Errorf("Error retrieving users: %s", err)
+		}
+
+		for _, user := range users {
+			for _, username := range usernames {
+				if user.Name == username {
+					return fmt.Errorf("User still exists: %s", username)
+				}
+			}
+		}
+
+		return nil
+	}
+}
+
+func testAccUserPasswordRotationConfig(keeper string) string {
+	return fmt.Sprintf(`
+resource "rabbitmq_user_password_rotation" "test" {
+    name = "mcrotated"
+    overlap = "720h"
+
+    permissions {
+        vhost = "/"
+        configure = ".*"
+        write = ".*"
+        read = ".*"
+    }
+
+    keepers = {
+        rotation = "%s"
+    }
+}`, keeper)
+}
@@ -2,6 +2,7 @@ package rabbitmq
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -145,6 +146,145 @@ func TestAccUser_passwordChange(t *testing.T) {
 	})
 }
 
+func TestAccUser_tagsRemovedOutOfBand(t *testing.T) {
+	var user string
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccUserCheckDestroy(user),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccUserConfig_noTags_2,
+				Check: resource.ComposeTestCheckFunc(
+					testAccUserCheck("rabbitmq_user.test", &user),
+					testAccUserCheckTagCount(&user, 1),
+				),
+			},
+			{
+				PreConfig: func() {
+					rmqc := testAccProvider.Meta().(*rabbitmqClient)
+					rmqc.PutUser("mctest", rabbithole.UserSettings{Password: "foobar"})
+				},
+				Config:             testAccUserConfig_noTags_2,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: testAccUserConfig_noTags_2,
+				Check:  testAccUserCheckTagCount(&user, 1),
+			},
+		},
+	})
+}
+
+func TestAccUser_passwordHash(t *testing.T) {
+	var user string
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccUserCheckDestroy(user),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccUserConfig_passwordHash,
+				Check: resource.ComposeTestCheckFunc(
+					testAccUserCheck("rabbitmq_user.test", &user),
+					testAccUserConnect("mctest", "foobar"),
+				),
+			},
+			{
+				Config: testAccUserConfig_passwordless,
+				Check: resource.ComposeTestCheckFunc(
+					testAccUserCheck("rabbitmq_user.test", &user),
+					resource.TestCheckResourceAttr("rabbitmq_user.test", "passwordless", "true"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccUser_passwordDrift(t *testing.T) {
+	var user string
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccUserCheckDestroy(user),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccUserConfig_passwordDrift,
+				Check: resource.ComposeTestCheckFunc(
+					testAccUserCheck("rabbitmq_user.test", &user),
+					testAccUserConnect("mctest", "foobar"),
+				),
+			},
+			{
+				PreConfig: func() {
+					rmqc := testAccProvider.Meta().(*rabbitmqClient)
+					rmqc.PutUser("mctest", rabbithole.UserSettings{Password: "changed", Tags: "management"})
+				},
+				Config:             testAccUserConfig_passwordDrift,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: testAccUserConfig_passwordDrift,
+				Check:  testAccUserConnect("mctest", "foobar"),
+			},
+		},
+	})
+}
+
+func TestUserPasswordMatches(t *testing.T) {
+	user := &rabbithole.UserInfo{
+		Name:             "mctest",
+		PasswordHash:     "YWJjZIUHTppFMIkIuUJqA+tJ1PY/hi7ldTD7Dz4uYusT6Uen",
+		HashingAlgorithm: rabbithole.HashingAlgorithmSHA256,
+	}
+
+	if !userPasswordMatches(user, "foobar") {
+		t.Errorf("userPasswordMatches failed for the current password")
+	}
+
+	if userPasswordMatches(user, "foobarry") {
+		t.Errorf("userPasswordMatches failed for a changed password")
+	}
+
+	if userPasswordMatches(&rabbithole.UserInfo{Name: "mctest"}, "foobar") {
+		t.Errorf("userPasswordMatches failed for a passwordless user")
+	}
+}
+
+func TestResourceUserStateUpgradeV0(t *testing.T) {
+	rawState := map[string]interface{}{
+		"name":     "mctest",
+		"password": "foobar",
+		"tags":     []interface{}{"management", "administrator", "management"},
+	}
+
+	actual, err := resourceUserStateUpgradeV0(rawState, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []interface{}{"management", "administrator"}
+	if !reflect.DeepEqual(actual["tags"], expected) {
+		t.Errorf("Expected tags %#v, got %#v", expected, actual["tags"])
+	}
+}
+
+func TestValidateUserTag(t *testing.T) {
+	for _, tag := range []string{"", "administrator", "monitoring", "policymaker", "management", "impersonator"} {
+		ws, errs := validateUserTag(tag, "tags")
+		if len(ws) != 0 || len(errs) != 0 {
+			t.Errorf("validateUserTag failed for: %s.", tag)
+		}
+	}
+
+	ws, errs := validateUserTag("adminstrator", "tags")
+	if len(ws) != 1 || len(errs) != 0 {
+		t.Errorf("validateUserTag should warn about unknown tags")
+	}
+}
+
 func testAccUserCheck(rn string, name *string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[rn]
@@ -156,7 +296,7 @@ func testAccUserCheck(rn string, name *string) resource.TestCheckFunc {
 			return fmt.Errorf("user id not set")
 		}
 
-		rmqc := testAccProvider.Meta().(*rabbithole.Client)
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
 		users, err := rmqc.ListUsers()
 		if err != nil {
 			return fmt.Errorf("Error retrieving users: %s", err)
@@ -190,7 +330,7 @@ func testAccUserConnect(username, password string) resource.TestCheckFunc {
 
 func testAccUserCheckTagCount(name *string, tagCount int) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
-		rmqc := testAccProvider.Meta().(*rabbithole.Client)
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
 		user, err := rmqc.GetUser(*name)
 		if err != nil {
 			return fmt.Errorf("Error retrieving user: %s", err)
@@ -213,7 +353,7 @@ func testAccUserCheckTagCount(name *string, tagCount int) resource.TestCheckFunc
 
 func testAccUserCheckDestroy(name string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
-		rmqc := testAccProvider.Meta().(*rabbithole.Client)
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
 		users, err := rmqc.ListUsers()
 		if err != nil {
 			return fmt.Errorf("Error retrieving users: %s", err)
@@ -297,3 +437,32 @@ resource "rabbitmq_user" "test" {
     password = "foobarry"
     tags = ["administrator", "management"]
 }`
+
+const testAccUserConfig_passwordHash = `
+data "rabbitmq_password_hash" "test" {
+    password = "foobar"
+    salt = "abcd"
+    hashing_algorithm = "rabbit_password_hashing_sha512"
+}
+
+resource "rabbitmq_user" "test" {
+    name = "mctest"
+    password_hash = "${data.rabbitmq_password_hash.test.password_hash}"
+    hashing_algorithm = "rabbit_password_hashing_sha512"
+    tags = ["management"]
+}`
+
+const testAccUserConfig_passwordless = `
+resource "rabbitmq_user" "test" {
+    name = "mctest"
+    passwordless = true
+    tags = ["management"]
+}`
+
+const testAccUserConfig_passwordDrift = `
+resource "rabbitmq_user" "test" {
+    name = "mctest"
+    password = "foobar"
+    detect_password_drift = true
+    tags = ["management"]
+}`
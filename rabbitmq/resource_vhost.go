@@ -29,7 +29,7 @@ func resourceVhost() *schema.Resource {
 }
 
 func CreateVhost(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
 	vhost := d.Get("name").(string)
 
@@ -47,7 +47,7 @@ func CreateVhost(d *schema.ResourceData, meta interface{}) error {
 }
 
 func ReadVhost(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
 	vhost, err := rmqc.GetVhost(d.Id())
 	if err != nil {
@@ -62,7 +62,7 @@ func ReadVhost(d *schema.ResourceData, meta interface{}) error {
 }
 
 func DeleteVhost(d *schema.ResourceData, meta interface{}) error {
-	rmqc := meta.(*rabbithole.Client)
+	rmqc := meta.(*rabbitmqClient)
 
 	log.Printf("[DEBUG] RabbitMQ: Attempting to delete vhost %s", d.Id())
 
@@ -0,0 +1,229 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+func resourceVhostPermissions() *schema.Resource {
+	return &schema.Resource{
+		Create: CreateVhostPermissions,
+		Update: UpdateVhostPermissions,
+		Read:   ReadVhostPermissions,
+		Delete: DeleteVhostPermissions,
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"vhost": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+
+			"permissions": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"user": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+
+						"configure": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validatePermissionRegexp(false),
+						},
+
+						"write": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validatePermissionRegexp(false),
+						},
+
+						"read": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validatePermissionRegexp(false),
+						},
+					},
+				},
+			},
+
+			"exclude_users": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+
+			"exclude_provider_user": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  true,
+			},
+		},
+	}
+}
+
+func CreateVhostPermissions(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	vhost := d.Get("vhost").(string)
+
+	if err := applyVhostPermissions(rmqc, vhost, d); err != nil {
+		return err
+	}
+
+	d.SetId(vhost)
+
+	return ReadVhostPermissions(d, meta)
+}
+
+func ReadVhostPermissions(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	vhost := d.Id()
+
+	if _, err := rmqc.GetVhost(vhost); err != nil {
+		return checkDeleted(d, err)
+	}
+
+	vhostPerms, err := listPermissionsIn(rmqc, vhost)
+	if err != nil {
+		return checkDeleted(d, err)
+	}
+
+	// Every grant of the vhost is read, so that grants added outside of
+	// Terraform show up as a diff and are revoked on the next apply. The
+	// grants of excluded users are never revoked, so they are only read when
+	// they are configured.
+	configured := vhostPermissionsUsers(d)
+	excluded := vhostPermissionsExcludedUsers(rmqc, d)
+	perms := make([]map[string]interface{}, 0)
+	for _, perm := range vhostPerms {
+		if excluded[perm.User] && !configured[perm.User] {
+			continue
+		}
+
+		perms = append(perms, map[string]interface{}{
+			"user":      perm.User,
+			"configure": perm.Configure,
+			"write":     perm.Write,
+			"read":      perm.Read,
+		})
+	}
+
+	log.Printf("[DEBUG] RabbitMQ: Permissions retrieved for vhost %s: %#v", vhost, perms)
+
+	d.Set("vhost", vhost)
+	d.Set("permissions", perms)
+	d.Set("exclude_provider_user", vhostPermissionsExcludeProviderUser(d))
+
+	return nil
+}
+
+func UpdateVhostPermissions(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	if err := applyVhostPermissions(rmqc, d.Id(), d); err != nil {
+		return err
+	}
+
+	return ReadVhostPermissions(d, meta)
+}
+
+func DeleteVhostPermissions(d *schema.ResourceData, meta interface{}) error {
+	rmqc := meta.(*rabbitmqClient)
+
+	vhost := d.Id()
+	for _, perm := range d.Get("permissions").(*schema.Set).List() {
+		permsMap, ok := perm.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("Unable to parse permissions")
+		}
+
+		if err := clearPermissionsIn(rmqc, vhost, permsMap["user"].(string)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyVhostPermissions grants the configured permissions, then revokes the
+// permissions of every other user of the vhost that isn't excluded.
+func applyVhostPermissions(rmqc *rabbitmqClient, vhost string, d *schema.ResourceData) error {
+	managed := map[string]bool{}
+	for _, perm := range d.Get("permissions").(*schema.Set).List() {
+		permsMap, ok := perm.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("Unable to parse permissions")
+		}
+
+		user := permsMap["user"].(string)
+		if managed[user] {
+			return fmt.Errorf("Permissions for user %s are defined more than once", user)
+		}
+		managed[user] = true
+
+		if err := setPermissionsIn(rmqc, vhost, user, permsMap); err != nil {
+			return err
+		}
+	}
+
+	vhostPerms, err := listPermissionsIn(rmqc, vhost)
+	if err != nil {
+		return err
+	}
+
+	excluded := vhostPermissionsExcludedUsers(rmqc, d)
+	for _, perm := range vhostPerms {
+		if managed[perm.User] || excluded[perm.User] {
+			continue
+		}
+
+		log.Printf("[DEBUG] RabbitMQ: Revoking unmanaged permissions of %s@%s", perm.User, vhost)
+		if err := clearPermissionsIn(rmqc, vhost, perm.User); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// vhostPermissionsUsers returns the users whose permissions are configured.
+func vhostPermissionsUsers(d *schema.ResourceData) map[string]bool {
+	users := map[string]bool{}
+	for _, perm := range d.Get("permissions").(*schema.Set).List() {
+		if permsMap, ok := perm.(map[string]interface{}); ok {
+			users[permsMap["user"].(string)] = true
+		}
+	}
+
+	return users
+}
+
+func vhostPermissionsExcludedUsers(rmqc *rabbitmqClient, d *schema.ResourceData) map[string]bool {
+	excluded := map[string]bool{}
+	for _, user := range d.Get("exclude_users").(*schema.Set).List() {
+		excluded[user.(string)] = true
+	}
+
+	if vhostPermissionsExcludeProviderUser(d) {
+		excluded[rmqc.Username] = true
+	}
+
+	return excluded
+}
+
+// vhostPermissionsExcludeProviderUser returns exclude_provider_user, which is
+// true when unset since defaults aren't applied on import.
+func vhostPermissionsExcludeProviderUser(d *schema.ResourceData) bool {
+	v, ok := d.GetOkExists("exclude_provider_user")
+	return !ok || v.(bool)
+}
@@ -0,0 +1,159 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"testing"
+
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+)
+
+func TestAccVhostPermissions(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccVhostPermissionsCheckDestroy("test"),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccVhostPermissionsConfig_basic,
+				Check: testAccVhostPermissionsCheck(
+					"rabbitmq_vhost_permissions.test", []string{"mctest"},
+				),
+			},
+			{
+				PreConfig: func() {
+					rmqc := testAccProvider.Meta().(*rabbitmqClient)
+					rmqc.UpdatePermissionsIn("test", "mctest2", rabbithole.Permissions{Configure: ".*", Write: ".*", Read: ".*"})
+				},
+				Config:             testAccVhostPermissionsConfig_basic,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: testAccVhostPermissionsConfig_basic,
+				Check: testAccVhostPermissionsCheck(
+					"rabbitmq_vhost_permissions.test", []string{"mctest"},
+				),
+			},
+			{
+				Config: testAccVhostPermissionsConfig_update,
+				Check: testAccVhostPermissionsCheck(
+					"rabbitmq_vhost_permissions.test", []string{"mctest", "mctest2"},
+				),
+			},
+		},
+	})
+}
+
+func testAccVhostPermissionsCheck(rn string, users []string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[rn]
+		if !ok {
+			return fmt.Errorf("resource not found: %s", rn)
+		}
+
+		if rs.Primary.ID == "" {
+			return fmt.Errorf("vhost permissions id not set")
+		}
+
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
+		perms, err := rmqc.ListPermissions()
+		if err != nil {
+			return fmt.Errorf("Error retrieving permissions: %s", err)
+		}
+
+		granted := map[string]bool{}
+		for _, perm := range perms {
+			if perm.Vhost == rs.Primary.ID && perm.User != rmqc.Username {
+				granted[perm.User] = true
+			}
+		}
+
+		if len(granted) != len(users) {
+			return fmt.Errorf("Expected permissions for %v, got %v", users, granted)
+		}
+		for _, user := range users {
+			if !granted[user] {
+				return fmt.Errorf("Unable to find permissions for user %s", user)
+			}
+		}
+
+		return nil
+	}
+}
+
+func testAccVhostPermissionsCheckDestroy(vhost string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rmqc := testAccProvider.Meta().(*rabbitmqClient)
+		perms, err := rmqc.ListPermissions()
+		if err != nil {
+			return fmt.Errorf("Error retrieving permissions: %s", err)
+		}
+
+		for _, perm := range perms {
+			if perm.Vhost == vhost {
+				return fmt.Errorf("Permissions still exist for user %s@%s", perm.User, perm.Vhost)
+			}
+		}
+
+		return nil
+	}
+}
+
+const testAccVhostPermissionsConfig_basic = `
+resource "rabbitmq_vhost" "test" {
+    name = "test"
+}
+
+resource "rabbitmq_user" "test" {
+    name = "mctest"
+    password = "foobar"
+}
+
+resource "rabbitmq_user" "test2" {
+    name = "mctest2"
+    password = "foobar"
+}
+
+resource "rabbitmq_vhost_permissions" "test" {
+    vhost = "${rabbitmq_vhost.test.name}"
+    permissions {
+        user = "${rabbitmq_user.test.name}"
+        configure = ".*"
+        write = ".*"
+        read = ".*"
+    }
+}`
+
+const testAccVhostPermissionsConfig_update = `
+resource "rabbitmq_vhost" "test" {
+    name = "test"
+}
+
+resource "rabbitmq_user" "test" {
+    name = "mctest"
+    password = "foobar"
+}
+
+resource "rabbitmq_user" "test2" {
+    name = "mctest2"
+    password = "foobar"
+}
+
+resource "rabbitmq_vhost_permissions" "test" {
+    vhost = "${rabbitmq_vhost.test.name}"
+    permissions {
+        user = "${rabbitmq_user.test.name}"
+        configure = ".*"
+        write = ".*"
+        read = ".*"
+    }
+    permissions {
+        user = "${rabbitmq_user.test2.name}"
+        configure = ""
+        write = "^amq\\.topic$"
+        read = ""
+    }
+}`
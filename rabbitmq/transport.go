@@ -0,0 +1,389 @@
+package rabbitmq
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/logging"
+)
+
+// How long a single health check against a management endpoint may take
+// before the endpoint is considered unavailable.
+const endpointHealthCheckTimeout = 10 * time.Second
+
+// failoverTransport spreads requests over several management endpoints of
+// the same cluster. One endpoint is used for every request of a run so that
+// reads observe earlier writes; another one is only picked when the current
+// endpoint cannot be reached or answers with a server error. Writes are only
+// sent to another endpoint when the connection to the current one couldn't
+// be established, so that they are never applied twice.
+type failoverTransport struct {
+	endpoints []*url.URL
+	transport http.RoundTripper
+
+	mu      sync.Mutex
+	current int
+}
+
+func newFailoverTransport(endpoints []string, transport http.RoundTripper) (*failoverTransport, error) {
+	t := &failoverTransport{transport: transport}
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse endpoint %s: %s", endpoint, err)
+		}
+		t.endpoints = append(t.endpoints, u)
+	}
+
+	return t, nil
+}
+
+// selectHealthy checks the endpoints in order and makes the first healthy
+// one sticky. It returns the URL of the selected endpoint.
+func (t *failoverTransport) selectHealthy(username, password string) (string, error) {
+	for i, endpoint := range t.endpoints {
+		err := t.checkHealth(endpoint, username, password)
+		if err == nil {
+			log.Printf("[DEBUG] RabbitMQ: Using management endpoint %s", endpoint)
+			t.stick(i)
+			return endpoint.String(), nil
+		}
+
+		log.Printf("[WARN] RabbitMQ: Management endpoint %s is unhealthy: %s", endpoint, err)
+	}
+
+	return "", fmt.Errorf("None of the configured RabbitMQ endpoints is healthy")
+}
+
+func (t *failoverTransport) checkHealth(endpoint *url.URL, username, password string) error {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(endpoint.String(), "/")+"/api/overview", nil)
+	if err != nil {
+		return err
+	}
+	req.Close = true
+	req.SetBasicAuth(username, password)
+
+	httpc := &http.Client{
+		Transport: t.transport,
+		Timeout:   endpointHealthCheckTimeout,
+	}
+	resp, err := httpc.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("health check returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (t *failoverTransport) stick(i int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.current = i
+}
+
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	start := t.current
+	t.mu.Unlock()
+
+	var resp *http.Response
+	var err error
+	for i := 0; i < len(t.endpoints); i++ {
+		n := (start + i) % len(t.endpoints)
+
+		attempt, rerr := t.rewrite(req, n, i > 0)
+		if rerr != nil {
+			// The request body can't be replayed, so report the last result.
+			if resp == nil && err == nil {
+				err = rerr
+			}
+			break
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		resp, err = t.transport.RoundTrip(attempt)
+		if err == nil && resp.StatusCode < 500 {
+			if n != start {
+				t.stick(n)
+			}
+			return resp, nil
+		}
+
+		if err != nil {
+			log.Printf("[WARN] RabbitMQ: Request to %s failed: %s", t.endpoints[n], err)
+		} else {
+			log.Printf("[WARN] RabbitMQ: Request to %s returned %s", t.endpoints[n], resp.Status)
+		}
+
+		// The broker may already have applied a write it failed to answer.
+		if !idempotent(req.Method) && (err == nil || !isDialError(err)) {
+			break
+		}
+	}
+
+	return resp, err
+}
+
+func idempotent(method string) bool {
+	return method == "" || method == http.MethodGet || method == http.MethodHead
+}
+
+// isDialError reports whether err happened while connecting, before any part
+// of the request was sent.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// rewrite returns a copy of req that targets the n-th endpoint.
+func (t *failoverTransport) rewrite(req *http.Request, n int, retry bool) (*http.Request, error) {
+	r := req.Clone(req.Context())
+
+	if retry && req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, fmt.Errorf("Unable to replay request to %s", req.URL)
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+
+	target := t.endpoints[n]
+	basePath := ""
+	for _, endpoint := range t.endpoints {
+		if endpoint.Scheme == req.URL.Scheme && endpoint.Host == req.URL.Host {
+			basePath = strings.TrimSuffix(endpoint.Path, "/")
+			break
+		}
+	}
+
+	r.URL.Scheme = target.Scheme
+	r.URL.Host = target.Host
+	r.Host = target.Host
+	r.URL.Path = strings.TrimSuffix(target.Path, "/") + strings.TrimPrefix(req.URL.Path, basePath)
+	if req.URL.RawPath != "" {
+		r.URL.RawPath = strings.TrimSuffix(target.EscapedPath(), "/") + strings.TrimPrefix(req.URL.RawPath, basePath)
+	}
+
+	return r, nil
+}
+
+// loggingTransport traces every request sent to the management API at TRACE
+// level. Credentials are redacted from headers and JSON bodies.
+type loggingTransport struct {
+	transport http.RoundTripper
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if logging.LogLevel() != "TRACE" {
+		return t.transport.RoundTrip(req)
+	}
+
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = body
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	start := time.Now()
+	resp, err := t.transport.RoundTrip(req)
+	latency := time.Since(start)
+
+	if err != nil {
+		log.Printf("[TRACE] RabbitMQ: HTTP request failed: method=%s path=%s latency=%s headers=%v request=%s error=%s",
+			req.Method, req.URL.Path, latency, redactHeaders(req.Header), redactBody(reqBody), err)
+		return resp, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	log.Printf("[TRACE] RabbitMQ: HTTP request: method=%s path=%s status=%d latency=%s headers=%v request=%s response=%s",
+		req.Method, req.URL.Path, resp.StatusCode, latency, redactHeaders(req.Header), redactBody(reqBody), redactBody(respBody))
+
+	return resp, nil
+}
+
+const redacted = "REDACTED"
+
+func redactHeaders(headers http.Header) http.Header {
+	h := headers.Clone()
+	if h.Get("Authorization") != "" {
+		h.Set("Authorization", redacted)
+	}
+	return h
+}
+
+// redactBody returns a JSON body with passwords and URI credentials
+// replaced. Other bodies are only described by their length.
+func redactBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fmt.Sprintf("(%d bytes)", len(body))
+	}
+
+	b, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return fmt.Sprintf("(%d bytes)", len(body))
+	}
+	return string(b)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, val := range value {
+			if strings.Contains(strings.ToLower(key), "password") {
+				value[key] = redacted
+			} else {
+				value[key] = redactValue(val)
+			}
+		}
+	case []interface{}:
+		for i, val := range value {
+			value[i] = redactValue(val)
+		}
+	case string:
+		return RedactURI(value)
+	}
+	return v
+}
+
+// RedactURI hides the password of AMQP URIs, such as the ones used by shovels
+// and federation upstreams. Other strings are returned as they are.
+func RedactURI(s string) string {
+	if !strings.HasPrefix(s, "amqp://") && !strings.HasPrefix(s, "amqps://") {
+		return s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	return u.String()
+}
+
+// limitingTransport caps the number of requests in flight and the rate at
+// which requests are sent, so that large refreshes don't overload the
+// management plugin. A zero limit disables the corresponding check.
+type limitingTransport struct {
+	transport http.RoundTripper
+
+	slots chan struct{}
+
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newLimitingTransport(maxConcurrent int, requestsPerSecond float64, transport http.RoundTripper) *limitingTransport {
+	t := &limitingTransport{transport: transport}
+	if maxConcurrent > 0 {
+		t.slots = make(chan struct{}, maxConcurrent)
+	}
+	if requestsPerSecond > 0 {
+		t.interval = time.Duration(float64(time.Second) / requestsPerSecond)
+	}
+
+	return t
+}
+
+func (t *limitingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.wait(req); err != nil {
+		return nil, err
+	}
+
+	if t.slots == nil {
+		return t.transport.RoundTrip(req)
+	}
+
+	select {
+	case t.slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	defer func() { <-t.slots }()
+
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	// The slot is released once the response has been read, since rabbit-hole
+	// returns the responses of writes without closing their bodies.
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return resp, nil
+}
+
+// wait blocks until the request may be sent without exceeding the rate limit.
+func (t *limitingTransport) wait(req *http.Request) error {
+	if t.interval == 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	delay := t.next.Sub(now)
+	t.next = t.next.Add(t.interval)
+	t.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
@@ -1,8 +1,15 @@
 package rabbitmq
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
 	"fmt"
+	"math/big"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
@@ -35,19 +42,173 @@ func percentDecodeSlashes(s string) string {
 	return strings.Replace(strings.Replace(s, "%2F", "/", -1), "%25", "%", -1)
 }
 
+// Names and vhosts may contain "@", so both are escaped in resource IDs,
+// which then contain exactly one separator.
+var (
+	resourceIdEscaper   = strings.NewReplacer("%", "%25", "@", "%40")
+	resourceIdUnescaper = strings.NewReplacer("%40", "@", "%25", "%")
+)
+
+// ResourceId builds the name@vhost ID of the resources identified by a name
+// and a vhost, e.g. queues, exchanges and policies.
+func ResourceId(name, vhost string) string {
+	return resourceIdEscaper.Replace(name) + "@" + resourceIdEscaper.Replace(vhost)
+}
+
 // get the id of the resource from the ResourceData
 func parseResourceId(d *schema.ResourceData) (name, vhost string, err error) {
 	return parseId(d.Id())
 }
 
-// get the resource name and rabbitmq vhost from the resource id
+// get the resource name and rabbitmq vhost from the resource id. IDs created
+// before names were escaped are split on the last "@", since "@" is more
+// common in user and queue names than in vhost names.
 func parseId(resourceId string) (name, vhost string, err error) {
-	parts := strings.Split(resourceId, "@")
-	if len(parts) != 2 {
+	i := strings.LastIndex(resourceId, "@")
+	if i <= 0 || i == len(resourceId)-1 {
 		err = fmt.Errorf("Unable to parse resource id: %s", resourceId)
 		return
 	}
-	name = parts[0]
-	vhost = parts[1]
+	name = resourceIdUnescaper.Replace(resourceId[:i])
+	vhost = resourceIdUnescaper.Replace(resourceId[i+1:])
 	return
 }
+
+// importResourceId accepts both escaped and legacy name@vhost IDs, and stores
+// the ID in its escaped form.
+func importResourceId(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	name, vhost, err := parseResourceId(d)
+	if err != nil {
+		return nil, err
+	}
+
+	d.SetId(ResourceId(name, vhost))
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// resourceIdStateUpgraderV0 escapes the name@vhost IDs stored before names
+// were escaped. The ID is rebuilt from the name and vhost attributes, nameKey
+// being the attribute holding the name. The schema of these resources didn't
+// change with the ID format, so r describes version 0 as well.
+func resourceIdStateUpgraderV0(r *schema.Resource, nameKey string) schema.StateUpgrader {
+	return schema.StateUpgrader{
+		Type:    r.CoreConfigSchema().ImpliedType(),
+		Version: 0,
+		Upgrade: func(rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+			name, _ := rawState[nameKey].(string)
+			vhost, _ := rawState["vhost"].(string)
+
+			if name == "" || vhost == "" {
+				// Fall back on the legacy ID, which wasn't escaped.
+				id, _ := rawState["id"].(string)
+				i := strings.LastIndex(id, "@")
+				if i < 0 {
+					return nil, fmt.Errorf("Unable to parse resource id: %s", id)
+				}
+				name, vhost = id[:i], id[i+1:]
+			}
+
+			rawState["id"] = ResourceId(name, vhost)
+
+			return rawState, nil
+		},
+	}
+}
+
+// hashPassword computes a password hash the way RabbitMQ does: the base64
+// encoding of a 4 byte salt followed by the hash of the salt and the password.
+// Using a fixed salt makes the result deterministic.
+// (reference: https://www.rabbitmq.com/passwords.html#computing-password-hash)
+func hashPassword(password, salt string, algorithm rabbithole.HashingAlgorithm) (string, error) {
+	if len(salt) != 4 {
+		return "", fmt.Errorf("The salt must be 4 bytes long, got %d", len(salt))
+	}
+
+	var sum []byte
+	switch algorithm {
+	case rabbithole.HashingAlgorithmSHA256:
+		h := sha256.Sum256([]byte(salt + password))
+		sum = h[:]
+	case rabbithole.HashingAlgorithmSHA512:
+		h := sha512.Sum512([]byte(salt + password))
+		sum = h[:]
+	default:
+		return "", fmt.Errorf("Unsupported hashing algorithm: %s", algorithm)
+	}
+
+	return base64.StdEncoding.EncodeToString(append([]byte(salt), sum...)), nil
+}
+
+const passwordCharacters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// generatePassword returns a random password. Only letters and digits are
+// used so that the password can be embedded in AMQP URIs without escaping.
+func generatePassword(length int) (string, error) {
+	max := big.NewInt(int64(len(passwordCharacters)))
+
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("Unable to generate password: %s", err)
+		}
+		b[i] = passwordCharacters[n.Int64()]
+	}
+
+	return string(b), nil
+}
+
+// validateDuration checks that a value can be parsed with time.ParseDuration.
+func validateDuration(v interface{}, k string) (ws []string, errors []error) {
+	if _, err := time.ParseDuration(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q must be a duration such as \"720h\": %s", k, err))
+	}
+	return
+}
+
+// Constructs supported by Erlang's PCRE based regular expressions but not by
+// Go's regexp package. Patterns using them can't be compiled locally.
+var pcreOnlyConstructs = regexp.MustCompile(`\(\?[=!>]|\(\?<[=!]|\\[1-9]|[*+?}]\+|\\[hHvVRK]`)
+
+// A dot between two name characters is most likely meant as a literal dot.
+var unescapedDot = regexp.MustCompile(`[\w-]\.[\w-]`)
+
+// Variables expanded by RabbitMQ in topic permissions.
+var topicPermissionVariables = regexp.MustCompile(`\{(username|vhost|client_id)\}`)
+
+// validatePermissionRegexp checks a permission pattern at plan time. Patterns
+// are compiled with Go's regexp package, which accepts the common subset of
+// PCRE, and common mistakes are reported as warnings.
+func validatePermissionRegexp(expandVariables bool) schema.SchemaValidateFunc {
+	return func(v interface{}, k string) (ws []string, errors []error) {
+		value := v.(string)
+		if value == "" || value == ".*" || value == "^$" {
+			return
+		}
+
+		pattern := value
+		if expandVariables {
+			pattern = topicPermissionVariables.ReplaceAllString(pattern, "variable")
+		}
+
+		if _, err := regexp.Compile(pattern); err != nil {
+			if pcreOnlyConstructs.MatchString(pattern) {
+				ws = append(ws, fmt.Sprintf("%q: unable to fully validate %q: %s", k, value, err))
+			} else {
+				errors = append(errors, fmt.Errorf("%q: invalid regular expression %q: %s", k, value, err))
+			}
+			return
+		}
+
+		if !strings.HasPrefix(value, "^") {
+			ws = append(ws, fmt.Sprintf("%q: %q is not anchored with ^ and matches any name containing it", k, value))
+		}
+
+		if unescapedDot.MatchString(value) {
+			ws = append(ws, fmt.Sprintf("%q: %q contains a . that matches any character, use \\. to match a literal dot", k, value))
+		}
+
+		return
+	}
+}
@@ -1,13 +1,19 @@
 package rabbitmq
 
-import "testing"
+import (
+	"strings"
+	"testing"
+
+	rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+)
 
 func TestParseId(t *testing.T) {
 	var badInputs = []string{
 		"",
 		"foo/test",
 		"footest",
-		"foo@bar@test",
+		"@test",
+		"foo@",
 	}
 
 	for _, input := range badInputs {
@@ -25,6 +31,10 @@ func TestParseId(t *testing.T) {
 		{"foo@test", "foo", "test"},
 		{"foo@/", "foo", "/"},
 		{"foo/bar/baz@/", "foo/bar/baz", "/"},
+		{"svc%40corp.example@test", "svc@corp.example", "test"},
+		{"100%25@v%40host", "100%", "v@host"},
+		// Legacy IDs are split on the last "@"
+		{"foo@bar@test", "foo@bar", "test"},
 	}
 
 	for _, test := range goodInputs {
@@ -34,3 +44,136 @@ func TestParseId(t *testing.T) {
 		}
 	}
 }
+
+func TestFormatResourceId(t *testing.T) {
+	var inputs = []struct {
+		name     string
+		vhost    string
+		expected string
+	}{
+		{"foo", "/", "foo@/"},
+		{"orders@eu", "test", "orders%40eu@test"},
+		{"100%", "v@host", "100%25@v%40host"},
+	}
+
+	for _, test := range inputs {
+		id := ResourceId(test.name, test.vhost)
+		if id != test.expected {
+			t.Errorf("ResourceId failed for: %s@%s. Got %s", test.name, test.vhost, id)
+		}
+
+		name, vhost, err := parseId(id)
+		if err != nil || name != test.name || vhost != test.vhost {
+			t.Errorf("parseId failed to round trip: %s.", id)
+		}
+	}
+}
+
+func TestResourceIdStateUpgraderV0(t *testing.T) {
+	upgrader := resourceIdStateUpgraderV0(resourceQueue(), "name")
+
+	rawState := map[string]interface{}{
+		"id":    "orders@eu@test",
+		"name":  "orders@eu",
+		"vhost": "test",
+	}
+
+	actual, err := upgrader.Upgrade(rawState, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if actual["id"] != "orders%40eu@test" {
+		t.Errorf("Expected ID orders%%40eu@test, got %s", actual["id"])
+	}
+
+	actual, err = upgrader.Upgrade(map[string]interface{}{"id": "svc@corp.example@test"}, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if actual["id"] != "svc%40corp.example@test" {
+		t.Errorf("Expected ID svc%%40corp.example@test, got %s", actual["id"])
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	var inputs = []struct {
+		algorithm rabbithole.HashingAlgorithm
+		hash      string
+	}{
+		{rabbithole.HashingAlgorithmSHA256, "YWJjZIUHTppFMIkIuUJqA+tJ1PY/hi7ldTD7Dz4uYusT6Uen"},
+		{rabbithole.HashingAlgorithmSHA512, "YWJjZOZa+FYGzs/j3TBYPUlxeycEh/zgCfY0T/d/b5+vkXqfYA4DNey0e08SMzow6xAhZPoAIwkZkP53lD3rBwg/gcc="},
+	}
+
+	for _, test := range inputs {
+		hash, err := hashPassword("foobar", "abcd", test.algorithm)
+		if err != nil || hash != test.hash {
+			t.Errorf("hashPassword failed for: %s. Got %s", test.algorithm, hash)
+		}
+	}
+
+	if _, err := hashPassword("foobar", "abc", rabbithole.HashingAlgorithmSHA256); err == nil {
+		t.Errorf("hashPassword should reject salts that are not 4 bytes long")
+	}
+
+	if _, err := hashPassword("foobar", "abcd", rabbithole.HashingAlgorithmMD5); err == nil {
+		t.Errorf("hashPassword should reject unsupported algorithms")
+	}
+}
+
+func TestGeneratePassword(t *testing.T) {
+	password, err := generatePassword(32)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(password) != 32 {
+		t.Errorf("Expected a password of 32 characters, got %d", len(password))
+	}
+
+	for _, c := range password {
+		if !strings.ContainsRune(passwordCharacters, c) {
+			t.Errorf("Unexpected character in generated password: %q", c)
+		}
+	}
+
+	if other, _ := generatePassword(32); other == password {
+		t.Errorf("Expected generated passwords to differ")
+	}
+}
+
+func TestValidatePermissionRegexp(t *testing.T) {
+	var inputs = []struct {
+		input           string
+		expandVariables bool
+		warnings        int
+		errors          int
+	}{
+		{"", false, 0, 0},
+		{".*", false, 0, 0},
+		{"^app-.*", false, 0, 0},
+		{"^amq\\.gen.*", false, 0, 0},
+		{"^(app|svc)-.*$", false, 0, 0},
+		{"^app-(", false, 0, 1},
+		{"app-.*", false, 1, 0},
+		{"^amq.gen.*", false, 1, 0},
+		{"^(?!amq\\.).*", false, 1, 0},
+		{"^{username}\\..*", true, 0, 0},
+		{"^{vhost}-{client_id}$", true, 0, 0},
+	}
+
+	validate := validatePermissionRegexp(false)
+	validateTopic := validatePermissionRegexp(true)
+	for _, test := range inputs {
+		f := validate
+		if test.expandVariables {
+			f = validateTopic
+		}
+
+		ws, errs := f(test.input, "read")
+		if len(ws) != test.warnings || len(errs) != test.errors {
+			t.Errorf("validatePermissionRegexp failed for: %s. Got %v %v", test.input, ws, errs)
+		}
+	}
+}